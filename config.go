@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+)
+
+// config is a wrapper for the configuration file (JSON).
+// Options passed on the command line take precedence over these.
+type config struct {
+	BaseURL      string `json:"base_url"`      // Base URL for Netflix.
+	LoginPath    string `json:"login_path"`    // Path to the login page.
+	PasswordPath string `json:"password_path"` // Path to the password page.
+	SignoutPath  string `json:"signout_path"`  // Path to the sign-out page.
+	Selectors    string `json:"selectors"`     // Path to the selector profile.
+	Flow         string `json:"flow"`          // Path to the flow document.
+	RemoteURL    string `json:"remote_url"`    // DevTools endpoint of a browser.
+}
+
+// loadConfig reads the configuration file.
+func loadConfig(path string) (*config, error) {
+	var (
+		buf []byte
+		cfg = &config{}
+		err error
+	)
+
+	if path == "" {
+		return cfg, nil
+	}
+
+	if buf, err = ioutil.ReadFile(path); err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(buf, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return cfg, nil
+}
@@ -1,22 +1,28 @@
 package main
 
 const (
-	// netflixPasswordRoute is the default URL for logging into Netflix.
-	netflixPasswordRoute = "https://netflix.com/password"
+	// Subcommands.
+	cmdDoctor  = "doctor"          // Check the selectors against the pages.
+	cmdRecover = "recover"         // Finish the incomplete rotations in the journal.
+	cmdFlow    = "flow"            // Print the built-in flow document.
+	cmdSignout = "signout-devices" // Sign out of all devices (only).
 
-	// netflixMount is the base XPath for the page.
-	netflixMnt = `//*[@id="appMountPoint"]`
+	// The journal (for recovering interrupted rotations) is kept in this
+	// directory (under the home directory, unless overridden), encrypted
+	// with a key from the environment, or from a key file (generated on
+	// first use) kept apart from it, under the user's configuration
+	// directory (unless overridden).
+	journalDir        = ".netflix-passwd-rotate"
+	journalKeyDir     = "netflix-passwd-rotate"
+	journalKeyFile    = "journal.key"
+	journalKeyEnv     = "NETFLIX_JOURNAL_KEY"
+	journalKeyFileEnv = "NETFLIX_JOURNAL_KEY_FILE"
+	journalExt        = ".journal"
 
-	// netflixEval is a JavaScript expression to evaluate.
-	netflixEval = `
-	document.evaluate(
-		'%s', document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null
-	).singleNodeValue%s
-	`
-
-	// netflixVerifyWait is the maximum number of seconds to wait before
-	// evaluating the verify expression.
-	netflixVerifyWait = 4
+	// The PIN of a locked profile (see -profile) is read from the
+	// environment, or else prompted for on a terminal (see
+	// rotate.Options.PIN).
+	profilePINEnv = "NETFLIX_PROFILE_PIN"
 
 	// autoGenerateSymsAll has all the special characters password generation.
 	autoGenerateSymsAll = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
@@ -26,14 +32,4 @@ const (
 	// arguments. This skips `~' (tilde) as well.
 	// This is used for testing only.
 	autoGenerateSymsTest = "#%&()*+,-./:;<>?@[\\]^_{|}"
-
-	// Errors.
-	errExecFail   = 1 // Browser task execution failed.
-	errVerifyFail = 2 // Verification failed.
-	errLoginFail  = 3 // Login failed.
-	errUpdateFail = 4 // Update failed.
-	errFlagFail   = 5 // CLI options parsing or user input failed.
-	errAutoFail   = 6 // Password generation failed.
-	errTmpFail    = 7 // Creation of temporary directory failed.
-	errWriteFail  = 8 // File I/O failures.
 )
@@ -2,12 +2,32 @@
 Command netflix-passwd-rotate is a CLI for rotating passwords on Netflix.
 
 Usage:
-  netflix-passwd-rotate -username {user} -old-password {old-pw}
+  netflix-passwd-rotate [command] -username {user} -old-password {old-pw}
                         -new-password {new-pw} -auto-generate
                         -max-len {M} -num-digits {D} -no-upper
                         -num-symbols {S} -allow-repeat -no-color
                         -dev-logout -tmp-dir {tmp} -out-file {out}
 						-exec-path {bin} -wait-sec {W}
+                        -config {cfg} -base-url {url}
+                        -login-path {path} -password-path {path}
+                        -signout-path {path}
+                        -selectors {profile} -verbose -verify-new
+                        -journal {dir} -output {fmt} -site {name}
+                        -flow {doc} -debug-dir {dir} -har {file}
+                        -remote-debugging-url {url} -headful
+                        -code-file {file} -code-exec {cmd} -profile {name}
+
+Commands:
+  doctor                Check the selectors on the login and password pages
+                        (the password page needs -username, -old-password),
+                        without updating the password.
+  recover               Finish the incomplete rotations in the journal:
+                        login with the new password (and -old-password,
+                        if given) to find out which one works, and write
+                        the new password to its -out-file.
+  flow                  Print the built-in flow document (for -flow).
+  signout-devices       Login (with -username, -old-password), and sign
+                        out of all devices, without changing the password.
 
 Arguments:
   -username             Netflix username to login with.
@@ -20,6 +40,25 @@ Arguments:
   -out-file             Write the new password to file.
   -exec-path            Path to the `google-chrome' binary.
   -wait-sec             Time to wait for the operation.
+  -config               Path to the configuration file (JSON).
+  -base-url             Base URL for Netflix.
+  -login-path           Path to the login page.
+  -password-path        Path to the password page.
+  -signout-path         Path to the sign-out page.
+  -selectors            Path to the selector profile (JSON/YAML).
+  -verbose              Print debug messages.
+  -verify-new           Login with the new password, once updated.
+  -journal              Directory for the rotation journal.
+  -output               Output format (text or json).
+  -site                 Site to rotate the password on (netflix).
+  -flow                 Path to the flow document (JSON/YAML).
+  -debug-dir            Capture the page on failures, in here.
+  -har                  Record the network traffic (HAR) in here.
+  -remote-debugging-url Attach to a running browser (DevTools).
+  -headful              Show the browser, pause on unknown pages.
+  -code-file            Read the verification code from this file.
+  -code-exec            Run this command for the verification code.
+  -profile              Profile to pick on the profile gate.
 
 Other:
   For -auto-generate:
@@ -28,6 +67,165 @@ Other:
     -num-digits         The number of digits in the password.
     -no-upper           Disable upper-case letters in the password.
     -allow-repeat       Allow repetitions in the password.
+
+  For -out-file:
+    The file is opened before the browser is started, and the new
+    password is held in the journal until it is written.
+
+  For -debug-dir:
+    On failures, the page is captured into a new (timestamped)
+    directory in here: "screenshot.png" (the full page), "dom.html"
+    (with the password fields redacted), "console.log" (the browser
+    console messages) and "url.txt" (the current URL). The passwords
+    (and any profile PIN or verification code entered) are redacted
+    from all of them.
+
+  For -har:
+    The network traffic of the browsers (during the rotation) is
+    written as a HAR file. The values of the headers, the query
+    parameters and the form fields that carry credentials (or cookies)
+    are scrubbed, the bodies of the other requests are dropped, and
+    the passwords (and any profile PIN or verification code entered)
+    are redacted from everything else.
+
+  For -remote-debugging-url:
+    Either the websocket URL of the browser (ws://...), or its HTTP
+    address (http://host:port, the websocket URL is read from
+    /json/version); e.g., for a browser started with the flag
+    --remote-debugging-port=9222 (in a sidecar container, or a pool).
+    Every phase opens (and closes) its own tab, and -exec-path and
+    -tmp-dir are ignored. The tabs share the profile of the browser, so
+    the cookies for the site are cleared when every tab is opened, and
+    again when it is closed (no session carries over between the runs, or
+    the accounts, and none is left behind in the browser).
+
+  For -headful:
+    The browser window is shown, and the flow pauses on the pages it
+    cannot handle (e.g., a CAPTCHA, a verification code, or a consent
+    banner), instead of failing on them (or leaving them); an unknown page
+    is paused on once it stays for a moment. Take care of the page in the
+    window, then press Enter; the flow also resumes by itself once the
+    page moves on. The timeout (-wait-sec) is stopped while paused.
+
+  For the verification code (if the login asks for one):
+    The code is read from the output of -code-exec (a shell command,
+    with the username in the environment variable NETFLIX_USERNAME),
+    from -code-file (polled until it shows up, and removed once read),
+    or else prompted for. The timeout (-wait-sec) is stopped meanwhile,
+    for up to 5 minutes.
+
+  For -profile:
+    On the profile gate ("Who's watching?"), the profile is picked by
+    name, or else the first one without a lock. The PIN of a locked
+    profile is read from the environment variable NETFLIX_PROFILE_PIN,
+    or else prompted for (only on a terminal, without the echo; the
+    timeout is stopped meanwhile, for up to 5 minutes).
+
+  For -output json:
+    A single JSON object is written to the standard output (everything
+    else goes to the standard error), with the "command", "username",
+    "phase" reached (see -journal), "status", "exit" (the name of the
+    exit status), "reason" (the failure message), "kind" (of the
+    failure), the "timings" of each phase (in seconds), and
+    "devices_signed_out". Passwords are never included.
+
+  For -journal (default: ~/.netflix-passwd-rotate):
+    Every rotation is recorded (with the new password) until it is
+    complete, the update is turned down, or the old password is known to
+    still work. The entries are encrypted with a key from the environment
+    variable NETFLIX_JOURNAL_KEY, or from a key file (generated on first
+    use) at NETFLIX_JOURNAL_KEY_FILE, by default "journal.key" under the
+    user's configuration directory (e.g. ~/.config/netflix-passwd-rotate).
+    The key file is never kept in the journal directory (one left there is
+    moved out), so a copy of the journal alone (say, in a backup) does not
+    reveal the passwords. It does not protect them from anyone who can read
+    both the journal and the key (or the environment) as the same user.
+    A new key file is not generated while the journal has any entries (they
+    could not be decrypted with it). If the journal cannot be written, the
+    rotation goes on without it (with a warning), and cannot be recovered.
+
+  For -config (JSON, the command line takes precedence):
+    "base_url"          Same as -base-url (default: https://netflix.com).
+    "login_path"        Same as -login-path (default: none, the
+                        password page redirects to the login page).
+    "password_path"     Same as -password-path (default: /password).
+    "signout_path"      Same as -signout-path
+                        (default: /manageaccountaccess).
+    "selectors"         Same as -selectors.
+    "flow"              Same as -flow.
+    "remote_url"        Same as -remote-debugging-url.
+
+  For -selectors (JSON/YAML, overrides the built-in selectors):
+    "mount"             The base XPath; "{mount}" in the other XPaths
+                        expands to it (default: //*[@id="appMountPoint"]).
+    "login"             Selectors for the login page: "username",
+                        "password", "remember", "submit", "eval",
+                        "username_err", "password_err", "fail_err".
+    "update"            Selectors for the password page: "old_password",
+                        "new_password", "cnf_password", "logout",
+                        "submit", "eval", "old_password_err",
+                        "new_password_err", "cnf_password_err".
+    "profiles"          Selectors for the profile gate: "gate", "entry"
+                        (every profile), "lock" (within a profile), "pin".
+    "challenge"         Selectors for the verification code page: "code",
+                        "submit".
+    "signout"           Selectors for the page to sign out of all devices
+                        (see signout-devices): "submit", "eval".
+
+    Each selector is either an XPath, or a list of strategies which
+    are tried in this order (the first one that matches wins):
+    "id"                The `id' attribute.
+    "name"              The `name' attribute.
+    "aria"              The `aria-label' attribute.
+    "text"              The visible text of a button (or a label).
+    "xpath"             A positional XPath.
+    A match on a fallback strategy is logged as a warning.
+
+  For -flow (JSON/YAML, overrides the sections of the built-in flow):
+    "start"             Steps before the login page (e.g., navigate).
+    "login"             Steps on the login page.
+    "challenge"         Steps on the verification code page.
+    "profile"           Steps on the PIN prompt of a profile.
+    "update"            Steps on the password page.
+    "signout"           Steps on the page to sign out of all devices.
+
+    Each step has one action; the selectors are named by their section
+    (e.g., "login.username"), and the values are templates, with
+    {{.Username}}, {{.OldPassword}}, {{.NewPassword}}, {{.Code}},
+    {{.PIN}}, {{.DevLogout}}, {{.LoginURL}} and {{.PasswordURL}}:
+    "navigate"          Load a URL.
+    "wait-visible"      Wait for an element to be visible.
+    "send-keys"         Type the "value" into an element.
+    "click"             Click on an element ("submit": true, if the
+                        click submits the update; exactly one click
+                        in "update" has it).
+    "assert-present"    Fail if an element is not on the page.
+    "extract-text-on-error"
+                        Read the failure message from an element.
+    A step with "if" runs only if it expands to "true".
+
+Exit status:
+  0     Success.
+  1     Browser execution failed.
+  2     Verification failed (with an unclassified failure message).
+  3     Login failed.
+  4     Update failed.
+  5     Options parsing or user input failed.
+  6     Password generation failed.
+  7     Creation of the temporary directory failed.
+  8     Writing the password to the file failed.
+  9     Selector checks failed (doctor).
+  10    Landed on an unexpected page.
+  11    The update looked fine, but the new password does not work.
+  12    The update outcome was unknown, and the old password works.
+  13    The update outcome was unknown, and the new password works.
+  14    The update outcome was unknown, and neither password works.
+  15    Invalid email address (invalid-email).
+  16    Invalid phone number (invalid-phone).
+  17    Incorrect (current) password (incorrect-password).
+  18    A previous password was reused (reused-password).
+  19    Bad password length (password-length).
+  20    The password confirmation does not match (password-mismatch).
 */
 package main
 
@@ -40,12 +238,30 @@ func usage() {
 	fmt.Fprintf(os.Stderr,
 		"netflix-passwd-rotate: A CLI for rotating passwords on Netflix.         \n"+
 			"\nUsage:\n"+
-			"  netflix-passwd-rotate -username {user} -old-password {old-pw}     \n"+
+			"  netflix-passwd-rotate [command] -username {user}                  \n"+
+			"                        -old-password {old-pw}                      \n"+
 			"                        -new-password {new-pw} -auto-generate       \n"+
 			"                        -max-len {M} -num-digits {D} -no-upper      \n"+
 			"                        -num-symbols {S} -allow-repeat -no-color    \n"+
 			"                        -dev-logout -tmp-dir {tmp} -out-file {out}  \n"+
 			"                        -exec-path {bin} -wait-sec {W}              \n"+
+			"                        -config {cfg} -base-url {url}               \n"+
+			"                        -login-path {path} -password-path {path}    \n"+
+			"                        -signout-path {path}                        \n"+
+			"                        -selectors {profile} -verbose -verify-new   \n"+
+			"                        -journal {dir} -output {fmt} -site {name}   \n"+
+			"                        -flow {doc} -debug-dir {dir} -har {file}    \n"+
+			"                        -remote-debugging-url {url} -headful        \n"+
+			"                        -code-file {file} -code-exec {cmd}          \n"+
+			"                        -profile {name}                             \n"+
+			"\nCommands:\n"+
+			"  doctor                Check the selectors on the login and        \n"+
+			"                        password pages (without updating it).       \n"+
+			"  recover               Finish the incomplete rotations in the      \n"+
+			"                        journal (see -journal).                     \n"+
+			"  flow                  Print the built-in flow document.           \n"+
+			"  signout-devices       Login, and sign out of all devices (without \n"+
+			"                        changing the password).                     \n"+
 			"\nArguments:\n"+
 			"  -username             Netflix username to login with.             \n"+
 			"  -old-password         The current Netflix password.               \n"+
@@ -57,12 +273,71 @@ func usage() {
 			"  -out-file             Write the new password to file.             \n"+
 			"  -exec-path            Path to the `google-chrome' binary.         \n"+
 			"  -wait-sec             Time to wait for the operation.             \n"+
+			"  -config               Path to the configuration file (JSON).      \n"+
+			"  -base-url             Base URL for Netflix.                       \n"+
+			"  -login-path           Path to the login page.                     \n"+
+			"  -password-path        Path to the password page.                  \n"+
+			"  -signout-path         Path to the sign-out page.                  \n"+
+			"  -selectors            Path to the selector profile (JSON/YAML).   \n"+
+			"  -verbose              Print debug messages.                       \n"+
+			"  -verify-new           Login with the new password, once updated.  \n"+
+			"  -journal              Directory for the rotation journal.         \n"+
+			"  -output               Output format (text or json).               \n"+
+			"  -site                 Site to rotate the password on (netflix).   \n"+
+			"  -flow                 Path to the flow document (JSON/YAML).      \n"+
+			"  -debug-dir            Capture the page on failures, in here.      \n"+
+			"  -har                  Record the network traffic (HAR) in here.   \n"+
+			"  -remote-debugging-url Attach to a running browser (DevTools).     \n"+
+			"  -headful              Show the browser, pause on unknown pages.   \n"+
+			"  -code-file            Read the verification code from this file.  \n"+
+			"  -code-exec            Run this command for the verification code. \n"+
+			"  -profile              Profile to pick on the profile gate.        \n"+
 			"\nOther:\n"+
 			"  For -auto-generate:\n"+
 			"    -max-len            The maximum length of the password.         \n"+
 			"    -num-symbols        The number of symbols in the password.      \n"+
 			"    -num-digits         The number of digits in the password.       \n"+
 			"    -no-upper           Disable upper-case letters in the password. \n"+
-			"    -allow-repeat       Allow repetitions in the password.          \n",
+			"    -allow-repeat       Allow repetitions in the password.          \n"+
+			"  For -debug-dir (on failures, redacted):\n"+
+			"    screenshot.png, dom.html, console.log, url.txt.                 \n"+
+			"  For -har (credentials and cookies are scrubbed):\n"+
+			"    The network traffic of the browsers, as a HAR file.             \n"+
+			"  For -remote-debugging-url (ws://..., or http://host:port):\n"+
+			"    Open the tabs on a running browser, instead of starting one.    \n"+
+			"  For -headful (the timeout is stopped while paused):\n"+
+			"    Pause on unknown pages; take care of them, then press Enter.    \n"+
+			"  For the verification code (the timeout is stopped meanwhile):\n"+
+			"    -code-exec (with $NETFLIX_USERNAME), -code-file, or a prompt.   \n"+
+			"  For -profile (or else the first one without a lock):\n"+
+			"    The PIN is read from $NETFLIX_PROFILE_PIN, or else prompted for \n"+
+			"    (only on a terminal, without the echo).                         \n"+
+			"  For -config (JSON, the command line takes precedence):\n"+
+			"    \"base_url\"          Same as -base-url.                          \n"+
+			"    \"login_path\"        Same as -login-path.                        \n"+
+			"    \"password_path\"     Same as -password-path.                     \n"+
+			"    \"signout_path\"      Same as -signout-path.                      \n"+
+			"    \"selectors\"         Same as -selectors.                         \n"+
+			"    \"flow\"              Same as -flow.                              \n"+
+			"    \"remote_url\"        Same as -remote-debugging-url.              \n"+
+			"  For -selectors (JSON/YAML, overrides the built-in selectors):\n"+
+			"    \"mount\"             The base XPath, \"{mount}\" expands to it.    \n"+
+			"    \"login\"             username, password, remember, submit, eval, \n"+
+			"                        username_err, password_err, fail_err.       \n"+
+			"    \"update\"            old_password, new_password, cnf_password,   \n"+
+			"                        logout, submit, eval, old_password_err,     \n"+
+			"                        new_password_err, cnf_password_err.         \n"+
+			"    \"profiles\"          gate, entry, lock, pin.                     \n"+
+			"    \"challenge\"         code, submit.                               \n"+
+			"    \"signout\"           submit, eval.                               \n"+
+			"    Each selector is an XPath, or a list of strategies (in order):  \n"+
+			"    \"id\", \"name\", \"aria\" (label), \"text\" (button), \"xpath\".         \n"+
+			"  For -flow (JSON/YAML, overrides the sections of the built-in flow):\n"+
+			"    \"start\", \"login\", \"challenge\", \"profile\", \"update\",             \n"+
+			"    \"signout\" (steps, each with a single action):                   \n"+
+			"    \"navigate\", \"wait-visible\", \"send-keys\" (with \"value\"), \"click\" \n"+
+			"    (with \"submit\"), \"assert-present\", \"extract-text-on-error\".     \n"+
+			"    Values are templates (e.g., {{.OldPassword}}); a step with \"if\" \n"+
+			"    runs only if it expands to \"true\" (see: flow).                  \n",
 	)
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// runDoctor runs the selector checks in a new browser, and prints a table
+// of the results.
+func runDoctor(ctx context.Context, r *rotate.Rotator) error {
+	var (
+		checks []rotate.SelectorCheck
+		err    error
+	)
+
+	infColor(os.Stderr, "INF: Checking the selectors on: \"%s\".\n", r.BaseURL())
+
+	if checks, err = r.Doctor(ctx); err != nil {
+		return err
+	}
+
+	if !printChecks(os.Stdout, checks) {
+		return rotate.NewError(
+			rotate.PhaseDoctor, rotate.KindDoctorFail, nil,
+			"Some of the selectors have drifted",
+		)
+	}
+
+	okColor(os.Stdout, "INF: All the selectors were found.\n")
+	return nil
+}
+
+// printChecks prints a table of the checks, and reports if everything passed.
+func printChecks(w io.Writer, checks []rotate.SelectorCheck) bool {
+	const row = "%-*s  %-6s %s\n"
+
+	var (
+		check rotate.SelectorCheck
+		ok    = true
+		width = len("SELECTOR")
+	)
+
+	for _, check = range checks {
+		if len(check.Name) > width {
+			width = len(check.Name)
+		}
+	}
+
+	infColor(w, row, width, "SELECTOR", "STATUS", "DETAIL")
+
+	for _, check = range checks {
+		switch check.Status {
+		case rotate.CheckPass:
+			okColor(w, row, width, check.Name, check.Status, check.Detail)
+		case rotate.CheckSkip:
+			wrnColor(w, row, width, check.Name, check.Status, check.Detail)
+		default:
+			errColor(w, row, width, check.Name, check.Status, check.Detail)
+			ok = false
+		}
+	}
+
+	return ok
+}
@@ -1,11 +1,14 @@
 module github.com/clickyotomy/netflix-passwd-rotate
 
-go 1.12
+go 1.13
 
 require (
+	github.com/chromedp/cdproto v0.0.0-20190429085128-1aa4f57ff2a9
 	github.com/chromedp/chromedp v0.3.0
 	github.com/fatih/color v1.7.0
 	github.com/mattn/go-colorable v0.1.2 // indirect
 	github.com/sethvargo/go-password v0.1.2
 	golang.org/x/crypto v0.17.0
+	golang.org/x/sys v0.15.0
+	gopkg.in/yaml.v2 v2.4.0
 )
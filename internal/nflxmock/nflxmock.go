@@ -0,0 +1,410 @@
+/*
+Package nflxmock is a local stand-in for the Netflix login, password and
+sign-out pages, for running the end-to-end tests without talking to netflix.com.
+
+The pages mirror the element IDs and XPaths that the CLI depends on (see
+`rotate.Selectors' and `defaultSelectors'), are served on the default
+routes (see -login-path, -password-path and -signout-path), and simulate
+the following error states:
+
+  - Invalid email address (login).
+  - Invalid phone number (login).
+  - Passwords shorter than 4 or longer than 60 characters.
+  - Incorrect password (login and update).
+  - Reusing the current password (update).
+*/
+package nflxmock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+const (
+	// Routes served by the mock.
+	LoginRoute    = "/login"
+	PasswordRoute = "/password"
+	BrowseRoute   = "/browse"
+	SignoutRoute  = "/manageaccountaccess"
+
+	// Error messages (as displayed by Netflix).
+	ErrInvalidEmail  = "Please enter a valid email."
+	ErrInvalidPhone  = "Please enter a valid phone number."
+	ErrPasswordLen   = "Your password must contain between 4 and 60 characters."
+	ErrIncorrectPass = "Incorrect password. " +
+		"Please try again or you can reset your password."
+	ErrReusedPass = "Sorry, you cannot use a previous password. " +
+		"Please try another password."
+	ErrCurrentPass = "Your current password is incorrect."
+
+	// MsgUpdated is displayed after a successful update.
+	MsgUpdated = "Your password has been changed."
+
+	// MsgSignedOut is displayed after signing out of all devices.
+	MsgSignedOut = "You have been signed out of all devices."
+
+	// Password length limits.
+	minPasswordLen = 4
+	maxPasswordLen = 60
+
+	// Minimum number of digits for a phone number.
+	minPhoneLen = 7
+
+	// sessionCookie is the name of the cookie for logged in sessions.
+	sessionCookie = "NetflixId"
+)
+
+// Server is a mock Netflix server.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	username string
+	password string
+	sessions map[string]bool
+
+	signedOut bool // Set if the last update (or sign-out) signed out all devices.
+}
+
+// loginPage has the parameters for rendering the login page.
+type loginPage struct {
+	Action   string
+	Username string
+	UnameErr string
+	PwordErr string
+	Fail     string
+}
+
+// passwordPage has the parameters for rendering the password page.
+type passwordPage struct {
+	OldErr  string
+	NewErr  string
+	CnfErr  string
+	Updated bool
+}
+
+var (
+	// loginTmpl mirrors the structure of the Netflix login page:
+	//   appMountPoint/div/div[3]/div/div/div[1]/div/div[2]       (fail)
+	//   appMountPoint/div/div[3]/div/div/div[1]/form/div[1]/div[2] (username)
+	//   appMountPoint/div/div[3]/div/div/div[1]/form/div[2]/div[2] (password)
+	//   appMountPoint/div/div[3]/div/div/div[1]/form/div[3]/div/label
+	//   appMountPoint/div/div[3]/div/div/div[1]/form/button
+	loginTmpl = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Netflix</title></head><body>
+<div id="appMountPoint"><div>
+<div class="header"></div>
+<div class="background"></div>
+<div><div><div>
+<div>
+{{- if .Fail}}<div><div class="icon"></div><div>{{.Fail}}</div></div>{{end -}}
+<form method="post" action="{{.Action}}">
+<div><div><input id="id_userLoginId" name="userLoginId" type="text" value="{{.Username}}"></div>
+{{- if .UnameErr}}<div>{{.UnameErr}}</div>{{end}}</div>
+<div><div><input id="id_password" name="password" type="password"></div>
+{{- if .PwordErr}}<div>{{.PwordErr}}</div>{{end}}</div>
+<div><div><input id="bxid_rememberMe_true" name="rememberMe" type="checkbox"><label for="bxid_rememberMe_true">Remember me</label></div></div>
+<button type="submit">Sign In</button>
+</form>
+</div>
+</div></div></div>
+</div></div>
+</body></html>
+`))
+
+	// passwordTmpl mirrors the structure of the Netflix password page:
+	//   appMountPoint/div/div/div[2]/div/div/div[1]/div/div[2] (updated)
+	//   appMountPoint/div/div/div[2]/div/div/div/button[1]
+	//   //*[@id="lbl-password"]/div
+	//   //*[@id="lbl-pw_new"]/div
+	//   //*[@id="lbl-pw_confirm"]/div
+	passwordTmpl = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html><head><title>Netflix</title></head><body>
+<div id="appMountPoint"><div><div>
+<div class="header"></div>
+<div><div><div>
+{{- if .Updated}}
+<div><div><div class="icon"></div><div>` + MsgUpdated + `</div></div></div>
+{{- else}}
+<div>
+<form id="pw-form" method="post" action="` + PasswordRoute + `">
+<label id="lbl-password" for="password"><input id="password" name="currentPassword" type="password">
+{{- if .OldErr}}<div>{{.OldErr}}</div>{{end}}</label>
+<label id="lbl-pw_new" for="pw_new"><input id="pw_new" name="newPassword" type="password">
+{{- if .NewErr}}<div>{{.NewErr}}</div>{{end}}</label>
+<label id="lbl-pw_confirm" for="pw_confirm"><input id="pw_confirm" name="confirmNewPassword" type="password">
+{{- if .CnfErr}}<div>{{.CnfErr}}</div>{{end}}</label>
+<input id="bxid_signout_devices_signout_devices" name="signOutDevices" type="checkbox" checked>
+</form>
+<button type="submit" form="pw-form">Save</button>
+<button type="button">Cancel</button>
+</div>
+{{- end}}
+</div></div></div>
+</div></div></div>
+</body></html>
+`))
+
+	// signoutTmpl mirrors the page for signing out of all devices:
+	//   //*[@data-uia="signout-all-devices-button"]
+	//   //*[@data-uia="signout-all-devices-confirmation"] (signed out)
+	signoutTmpl = template.Must(template.New("signout").Parse(`<!DOCTYPE html>
+<html><head><title>Netflix</title></head><body>
+<div id="appMountPoint"><div><div>
+<div class="header"></div>
+<div>
+{{- if .}}
+<div data-uia="signout-all-devices-confirmation">` + MsgSignedOut + `</div>
+{{- else}}
+<form method="post" action="` + SignoutRoute + `">
+<button type="submit" data-uia="signout-all-devices-button">Sign Out</button>
+</form>
+{{- end}}
+</div>
+</div></div></div>
+</body></html>
+`))
+
+	// browseTmpl is the landing page after logging in.
+	browseTmpl = template.Must(template.New("browse").Parse(`<!DOCTYPE html>
+<html><head><title>Netflix</title></head><body>
+<div id="appMountPoint"><div><div class="browse"></div></div></div>
+</body></html>
+`))
+)
+
+// NewServer starts a mock server for an account with the given credentials.
+// The caller should call Close when finished, to shut it down.
+func NewServer(username, password string) *Server {
+	var (
+		s   *Server
+		mux *http.ServeMux
+	)
+
+	s = &Server{
+		username: username,
+		password: password,
+		sessions: make(map[string]bool),
+	}
+
+	mux = http.NewServeMux()
+	mux.HandleFunc(LoginRoute, s.login)
+	mux.HandleFunc(PasswordRoute, s.passwd)
+	mux.HandleFunc(BrowseRoute, s.browse)
+	mux.HandleFunc(SignoutRoute, s.signout)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Password returns the current password for the account.
+func (s *Server) Password() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.password
+}
+
+// SignedOut reports if the last update (or sign-out) signed out all devices.
+func (s *Server) SignedOut() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.signedOut
+}
+
+// login handles the login page.
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var (
+		page  loginPage
+		next  string
+		token string
+	)
+
+	// Only redirect within the mock.
+	next = BrowseRoute
+	if u, err := url.Parse(r.URL.Query().Get("nextpage")); err == nil &&
+		strings.HasPrefix(u.Path, "/") {
+		next = u.RequestURI()
+	}
+
+	page.Action = r.URL.RequestURI()
+
+	if r.Method != http.MethodPost {
+		render(w, loginTmpl, page)
+		return
+	}
+
+	page.Username = r.PostFormValue("userLoginId")
+	page.UnameErr = checkUsername(page.Username)
+	page.PwordErr = checkLength(r.PostFormValue("password"))
+
+	if page.UnameErr != "" || page.PwordErr != "" {
+		render(w, loginTmpl, page)
+		return
+	}
+
+	s.mu.Lock()
+	if page.Username != s.username || r.PostFormValue("password") != s.password {
+		s.mu.Unlock()
+
+		page.Fail = ErrIncorrectPass
+		render(w, loginTmpl, page)
+		return
+	}
+
+	token = newToken()
+	s.sessions[token] = true
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: token, Path: "/"})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// passwd handles the password page.
+func (s *Server) passwd(w http.ResponseWriter, r *http.Request) {
+	var (
+		page passwordPage
+		old  string
+		new  string
+		cnf  string
+	)
+
+	if !s.loggedIn(r) {
+		http.Redirect(
+			w, r, LoginRoute+"?nextpage="+PasswordRoute, http.StatusFound,
+		)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		render(w, passwordTmpl, page)
+		return
+	}
+
+	old = r.PostFormValue("currentPassword")
+	new = r.PostFormValue("newPassword")
+	cnf = r.PostFormValue("confirmNewPassword")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case old != s.password:
+		page.OldErr = ErrCurrentPass
+	case checkLength(new) != "":
+		page.NewErr = checkLength(new)
+	case new == s.password:
+		page.NewErr = ErrReusedPass
+	case cnf != new:
+		page.CnfErr = "Passwords must match."
+	default:
+		s.password = new
+		s.signedOut = r.PostFormValue("signOutDevices") != ""
+		page.Updated = true
+	}
+
+	render(w, passwordTmpl, page)
+}
+
+// signout handles the page for signing out of all devices; this ends every
+// session (including the current one).
+func (s *Server) signout(w http.ResponseWriter, r *http.Request) {
+	if !s.loggedIn(r) {
+		http.Redirect(
+			w, r, LoginRoute+"?nextpage="+SignoutRoute, http.StatusFound,
+		)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		render(w, signoutTmpl, false)
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions = make(map[string]bool)
+	s.signedOut = true
+	s.mu.Unlock()
+
+	render(w, signoutTmpl, true)
+}
+
+// browse handles the landing page.
+func (s *Server) browse(w http.ResponseWriter, r *http.Request) {
+	if !s.loggedIn(r) {
+		http.Redirect(w, r, LoginRoute, http.StatusFound)
+		return
+	}
+
+	render(w, browseTmpl, nil)
+}
+
+// loggedIn checks if the request has a valid session.
+func (s *Server) loggedIn(r *http.Request) bool {
+	var (
+		cookie *http.Cookie
+		err    error
+	)
+
+	if cookie, err = r.Cookie(sessionCookie); err != nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sessions[cookie.Value]
+}
+
+// checkUsername validates an email address or a phone number.
+func checkUsername(username string) string {
+	var (
+		at  int
+		dot int
+	)
+
+	if username != "" && strings.Trim(username, "0123456789+") == "" {
+		if len(username) < minPhoneLen {
+			return ErrInvalidPhone
+		}
+		return ""
+	}
+
+	at = strings.Index(username, "@")
+	dot = strings.LastIndex(username, ".")
+	if at < 1 || dot < at+2 || dot == len(username)-1 {
+		return ErrInvalidEmail
+	}
+
+	return ""
+}
+
+// checkLength validates the length of a password.
+func checkLength(password string) string {
+	if len(password) < minPasswordLen || len(password) > maxPasswordLen {
+		return ErrPasswordLen
+	}
+
+	return ""
+}
+
+// newToken generates a random session token.
+func newToken() string {
+	var buf = make([]byte, 16)
+
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// render writes out a page.
+func render(w http.ResponseWriter, tmpl *template.Template, data interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.Execute(w, data)
+}
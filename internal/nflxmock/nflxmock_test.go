@@ -0,0 +1,191 @@
+package nflxmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const (
+	testUsername = "stub@example.com"
+	testPassword = "stub-password"
+)
+
+// formParams is a struct for running tests against the mock.
+type formParams struct {
+	route  string     // Route to post the form to.
+	form   url.Values // Form values.
+	output string     // Expected output (substring).
+	absent string     // Unexpected output (substring).
+
+	comment string // What the test does.
+}
+
+// All the test cases go here.
+var FormTests = []formParams{
+	formParams{
+		route: LoginRoute,
+		form: url.Values{
+			"userLoginId": {"foo"}, "password": {testPassword},
+		},
+		output:  ErrInvalidEmail,
+		comment: "Test a bad email address.",
+	},
+	formParams{
+		route: LoginRoute,
+		form: url.Values{
+			"userLoginId": {"42"}, "password": {testPassword},
+		},
+		output:  ErrInvalidPhone,
+		comment: "Test a bad phone number.",
+	},
+	formParams{
+		route: LoginRoute,
+		form: url.Values{
+			"userLoginId": {testUsername}, "password": {"foo"},
+		},
+		output:  ErrPasswordLen,
+		comment: "Test a bad password.",
+	},
+	formParams{
+		route: LoginRoute,
+		form: url.Values{
+			"userLoginId": {testUsername}, "password": {"bar123"},
+		},
+		output:  ErrIncorrectPass,
+		comment: "Test an invalid password.",
+	},
+	formParams{
+		route: LoginRoute + "?nextpage=" + PasswordRoute,
+		form: url.Values{
+			"userLoginId": {testUsername}, "password": {testPassword},
+		},
+		output:  `id="pw_new"`,
+		comment: "Test login success.",
+	},
+	formParams{
+		route: PasswordRoute,
+		form: url.Values{
+			"currentPassword":    {testPassword},
+			"newPassword":        {testPassword},
+			"confirmNewPassword": {testPassword},
+		},
+		output:  ErrReusedPass,
+		comment: "Test reusing the current password.",
+	},
+	formParams{
+		route: PasswordRoute,
+		form: url.Values{
+			"currentPassword":    {testPassword},
+			"newPassword":        {strings.Repeat("x", 61)},
+			"confirmNewPassword": {strings.Repeat("x", 61)},
+		},
+		output:  ErrPasswordLen,
+		comment: "Test a long password.",
+	},
+	formParams{
+		route: PasswordRoute,
+		form: url.Values{
+			"currentPassword":    {"bar123"},
+			"newPassword":        {"baz123"},
+			"confirmNewPassword": {"baz123"},
+		},
+		output:  ErrCurrentPass,
+		comment: "Test an incorrect current password.",
+	},
+	formParams{
+		route: PasswordRoute,
+		form: url.Values{
+			"currentPassword":    {testPassword},
+			"newPassword":        {"baz123"},
+			"confirmNewPassword": {"baz123"},
+			"signOutDevices":     {"on"},
+		},
+		output:  MsgUpdated,
+		absent:  `id="pw_new"`,
+		comment: "Test reset success.",
+	},
+	formParams{
+		route:   SignoutRoute,
+		form:    url.Values{},
+		output:  MsgSignedOut,
+		comment: "Test signing out of all devices.",
+	},
+	formParams{
+		route:   SignoutRoute,
+		form:    url.Values{},
+		output:  `id="id_userLoginId"`,
+		absent:  MsgSignedOut,
+		comment: "Test that the current session was signed out as well.",
+	},
+}
+
+// TestServer walks through the login and update forms.
+func TestServer(t *testing.T) {
+	var (
+		srv  *Server
+		jar  *cookiejar.Jar
+		clt  *http.Client
+		resp *http.Response
+		test formParams
+		body []byte
+		err  error
+	)
+
+	srv = NewServer(testUsername, testPassword)
+	defer srv.Close()
+
+	jar, _ = cookiejar.New(nil)
+	clt = &http.Client{Jar: jar}
+
+	resp, err = clt.Get(srv.URL + PasswordRoute)
+	if err != nil {
+		t.Fatalf("error: unable to fetch the password page: %s", err)
+	}
+	resp.Body.Close()
+
+	if resp.Request.URL.Path != LoginRoute {
+		t.Fatalf(
+			"expected a redirect to %s, got: %s",
+			LoginRoute, resp.Request.URL.Path,
+		)
+	}
+
+	for _, test = range FormTests {
+		resp, err = clt.PostForm(srv.URL+test.route, test.form)
+		if err != nil {
+			t.Fatalf("error: unable to post the form: %s", err)
+		}
+
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("error: unable to read the response: %s", err)
+		}
+
+		if !strings.Contains(string(body), test.output) {
+			t.Fatalf(
+				"\nComment: %s\n\noutput:\n\twant:\t\"%s\"\n\tgot:\t\"%s\"\n",
+				test.comment, test.output, body,
+			)
+		}
+
+		if test.absent != "" && strings.Contains(string(body), test.absent) {
+			t.Fatalf(
+				"\nComment: %s\n\nunexpected output: \"%s\"\n",
+				test.comment, test.absent,
+			)
+		}
+	}
+
+	if srv.Password() != "baz123" {
+		t.Fatalf("expected the password to be updated")
+	}
+
+	if !srv.SignedOut() {
+		t.Fatalf("expected all devices to be signed out")
+	}
+}
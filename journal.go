@@ -0,0 +1,392 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+)
+
+// Phases of a rotation, as recorded in the journal.
+const (
+	jrnStarted   = "started"   // Nothing was done yet.
+	jrnLoggedIn  = "logged-in" // Logged in with the old password.
+	jrnSubmitted = "submitted" // The update is about to be submitted.
+	jrnVerified  = "verified"  // The new password is live.
+	jrnPersisted = "persisted" // The new password was written to the file.
+)
+
+// journal is a directory of encrypted entries, one for every rotation in
+// progress. Entries are removed once the rotation completes (or fails
+// without changing anything), so anything left behind is incomplete.
+type journal struct {
+	dir string
+	key [32]byte
+}
+
+// journalEntry is the record of a rotation.
+type journalEntry struct {
+	Username    string    `json:"username"`
+	Phase       string    `json:"phase"`
+	NewPassword string    `json:"new_password"`
+	OutFile     string    `json:"out_file,omitempty"`
+	Started     time.Time `json:"started"`
+	Updated     time.Time `json:"updated"`
+
+	path    string
+	jrn     *journal
+	discard bool // Nothing changed; remove the entry.
+}
+
+// openJournal opens (or creates) the journal directory, and loads the key.
+// The key is derived from `journalKeyEnv' if it is set, or read from the
+// key file (which is generated on first use, but not while the journal has
+// any entries). The key file is never kept in the journal directory, so
+// that a copy of the journal (say, in a backup) does not carry the means
+// to decrypt it.
+func openJournal(dir string) (*journal, error) {
+	var (
+		home string
+		path string
+		key  []byte
+		j    = &journal{dir: dir}
+		err  error
+	)
+
+	if j.dir == "" {
+		if home, err = os.UserHomeDir(); err != nil {
+			return nil, err
+		}
+		j.dir = filepath.Join(home, journalDir)
+	}
+
+	if err = os.MkdirAll(j.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	if pass := os.Getenv(journalKeyEnv); pass != "" {
+		j.key = sha3.Sum256([]byte(pass))
+		return j, nil
+	}
+
+	if path, err = j.keyPath(); err != nil {
+		return nil, err
+	}
+
+	key, err = ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		key, err = j.migrateKey(path)
+	}
+
+	// A new key cannot decrypt the entries already in the journal.
+	if os.IsNotExist(err) {
+		paths, _ := filepath.Glob(filepath.Join(j.dir, "*"+journalExt))
+		if len(paths) != 0 {
+			return nil, fmt.Errorf(
+				"%s: no such key file, for the %d entries in \"%s\" "+
+					"(restore it, or set %s to their key)",
+				path, len(paths), j.dir, journalKeyEnv,
+			)
+		}
+	}
+
+	switch {
+	case os.IsNotExist(err):
+		key = make([]byte, len(j.key))
+		if _, err = io.ReadFull(rand.Reader, key); err != nil {
+			return nil, err
+		}
+
+		if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+
+		if err = writeSync(path, key); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case len(key) != len(j.key):
+		return nil, fmt.Errorf("%s: bad key length", path)
+	}
+
+	copy(j.key[:], key)
+	return j, nil
+}
+
+// keyPath returns the path to the key file: from `journalKeyFileEnv' if it
+// is set, or else under the user's configuration directory. A key file in
+// the journal directory is refused.
+func (j *journal) keyPath() (string, error) {
+	var (
+		path = os.Getenv(journalKeyFileEnv)
+		conf string
+		dir  string
+		rel  string
+		err  error
+	)
+
+	if path == "" {
+		if conf, err = os.UserConfigDir(); err != nil {
+			return "", err
+		}
+		path = filepath.Join(conf, journalKeyDir, journalKeyFile)
+	}
+
+	if path, err = filepath.Abs(path); err != nil {
+		return "", err
+	}
+
+	if dir, err = filepath.Abs(j.dir); err != nil {
+		return "", err
+	}
+
+	rel, err = filepath.Rel(dir, path)
+	if err == nil && rel != ".." &&
+		!strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(
+			"%s: the key file cannot be in the journal directory", path,
+		)
+	}
+
+	return path, nil
+}
+
+// migrateKey moves a key file left in the journal directory (by earlier
+// versions) to its path, so that the entries it encrypted stay readable.
+func (j *journal) migrateKey(path string) ([]byte, error) {
+	var (
+		old      = filepath.Join(j.dir, journalKeyFile)
+		key, err = ioutil.ReadFile(old)
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	if err = writeSync(path, key); err != nil {
+		return nil, err
+	}
+
+	return key, os.Remove(old)
+}
+
+// begin creates an entry for a new rotation. Without a journal (nil), the
+// entry is only kept in memory.
+func (j *journal) begin(username, password, outFile string) (*journalEntry, error) {
+	var (
+		now = time.Now().UTC()
+		e   = &journalEntry{
+			Username:    username,
+			Phase:       jrnStarted,
+			NewPassword: password,
+			OutFile:     outFile,
+			Started:     now,
+			jrn:         j,
+		}
+	)
+
+	if j == nil {
+		return e, nil
+	}
+
+	e.path = filepath.Join(
+		j.dir, now.Format("20060102T150405.000000000Z")+journalExt,
+	)
+
+	return e, e.save()
+}
+
+// entries reads all the entries in the journal (oldest first).
+func (j *journal) entries() ([]*journalEntry, error) {
+	var (
+		paths   []string
+		buf     []byte
+		e       *journalEntry
+		entries []*journalEntry
+		err     error
+	)
+
+	paths, err = filepath.Glob(filepath.Join(j.dir, "*"+journalExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if buf, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+
+		if buf, err = j.open(buf); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+
+		e = &journalEntry{path: path, jrn: j}
+		if err = json.Unmarshal(buf, e); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// seal encrypts a buffer (AES-256-GCM); the nonce is prepended to it.
+func (j *journal) seal(buf []byte) ([]byte, error) {
+	var (
+		aead  cipher.AEAD
+		nonce []byte
+		err   error
+	)
+
+	if aead, err = j.aead(); err != nil {
+		return nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, buf, nil), nil
+}
+
+// open decrypts a buffer encrypted with seal.
+func (j *journal) open(buf []byte) ([]byte, error) {
+	var (
+		aead cipher.AEAD
+		err  error
+	)
+
+	if aead, err = j.aead(); err != nil {
+		return nil, err
+	}
+
+	if len(buf) < aead.NonceSize() {
+		return nil, fmt.Errorf("truncated entry")
+	}
+
+	return aead.Open(
+		nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil,
+	)
+}
+
+// aead returns the cipher for the journal.
+func (j *journal) aead() (cipher.AEAD, error) {
+	var (
+		block cipher.Block
+		err   error
+	)
+
+	if block, err = aes.NewCipher(j.key[:]); err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// advance records the next phase of the rotation.
+func (e *journalEntry) advance(phase string) error {
+	e.Phase = phase
+	return e.save()
+}
+
+// mark records the next phase of the rotation; failures are only
+// reported, for phases where there is no turning back.
+func (e *journalEntry) mark(phase string) {
+	if err := e.advance(phase); err != nil {
+		wrnColor(os.Stderr, "WRN: Unable to update the journal (%s).\n", err)
+	}
+}
+
+// save writes the entry to a temporary file, and renames it over the
+// previous one, so that a crash never leaves a partial entry behind.
+func (e *journalEntry) save() error {
+	var (
+		buf []byte
+		tmp = e.path + ".tmp"
+		err error
+	)
+
+	e.Updated = time.Now().UTC()
+
+	if e.jrn == nil {
+		return nil
+	}
+
+	if buf, err = json.Marshal(e); err != nil {
+		return err
+	}
+
+	if buf, err = e.jrn.seal(buf); err != nil {
+		return err
+	}
+
+	if err = writeSync(tmp, buf); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, e.path)
+}
+
+// remove removes the entry from the journal.
+func (e *journalEntry) remove() error {
+	if e.jrn == nil {
+		return nil
+	}
+
+	return os.Remove(e.path)
+}
+
+// done reports if the entry can be removed, i.e., if the rotation either
+// completed, or failed without changing the password.
+func (e *journalEntry) done() bool {
+	switch e.Phase {
+	case jrnStarted, jrnLoggedIn, jrnPersisted:
+		return true
+	}
+
+	return e.discard
+}
+
+// finish removes the entry if it is done, or tells the user how to
+// recover the rotation.
+func (e *journalEntry) finish() {
+	if e.done() {
+		e.remove()
+		return
+	}
+
+	if e.jrn == nil {
+		wrnColor(
+			os.Stderr,
+			"WRN: The rotation is incomplete (phase: %s), and there is no "+
+				"journal to recover it from.\n",
+			e.Phase,
+		)
+		return
+	}
+
+	wrnColor(
+		os.Stderr,
+		"WRN: The rotation is incomplete (phase: %s); run `%s' to finish it "+
+			"(journal: \"%s\").\n",
+		e.Phase, cmdRecover, e.path,
+	)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestJournal tests recording rotations in the journal.
+func TestJournal(t *testing.T) {
+	var (
+		dir     string
+		buf     []byte
+		fi      os.FileInfo
+		jrn     *journal
+		e       *journalEntry
+		entries []*journalEntry
+		err     error
+	)
+
+	dir, err = ioutil.TempDir("", "nflx-jrn-test")
+	if err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The key file is kept apart from the journal.
+	key := filepath.Join(dir, "conf", journalKeyFile)
+	dir = filepath.Join(dir, "journal")
+
+	os.Unsetenv(journalKeyEnv)
+	os.Setenv(journalKeyFileEnv, filepath.Join(dir, journalKeyFile))
+	defer os.Unsetenv(journalKeyFileEnv)
+
+	if _, err = openJournal(dir); err == nil {
+		t.Fatalf("expected a key file in the journal directory to fail")
+	}
+
+	os.Setenv(journalKeyFileEnv, key)
+	if jrn, err = openJournal(dir); err != nil {
+		t.Fatalf("error: unable to open the journal: %s", err)
+	}
+
+	if fi, err = os.Stat(key); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the key file to be private: %v (%v)", fi, err)
+	}
+
+	if e, err = jrn.begin("stub", "stub-password-new", "pw-file"); err != nil {
+		t.Fatalf("error: unable to begin an entry: %s", err)
+	}
+
+	if err = e.advance(jrnSubmitted); err != nil {
+		t.Fatalf("error: unable to advance the entry: %s", err)
+	}
+
+	// The entry is encrypted, and readable only by the user.
+	if fi, err = os.Stat(e.path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected the entry to be private: %v (%v)", fi.Mode(), err)
+	}
+
+	if buf, _ = ioutil.ReadFile(e.path); bytes.Contains(buf, []byte("stub")) {
+		t.Fatalf("expected the entry to be encrypted")
+	}
+
+	// The key is retained across runs.
+	if jrn, err = openJournal(dir); err != nil {
+		t.Fatalf("error: unable to reopen the journal: %s", err)
+	}
+
+	if entries, err = jrn.entries(); err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single entry, got: %d (%v)", len(entries), err)
+	}
+
+	// A key file left in the journal directory is moved out of it.
+	if err = os.Rename(key, filepath.Join(dir, journalKeyFile)); err != nil {
+		t.Fatalf("error: unable to move the key file: %s", err)
+	}
+
+	if jrn, err = openJournal(dir); err != nil {
+		t.Fatalf("error: unable to reopen the journal: %s", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(dir, journalKeyFile)); !os.IsNotExist(err) {
+		t.Fatalf("expected the key file to be moved: %v", err)
+	}
+
+	if entries, err = jrn.entries(); err != nil || len(entries) != 1 {
+		t.Fatalf("expected a single entry, got: %d (%v)", len(entries), err)
+	}
+
+	if entries[0].Phase != jrnSubmitted ||
+		entries[0].NewPassword != "stub-password-new" ||
+		entries[0].OutFile != "pw-file" {
+		t.Fatalf("unexpected entry: %+v", entries[0])
+	}
+
+	// An incomplete rotation is retained.
+	if entries[0].finish(); entries[0].done() {
+		t.Fatalf("expected a submitted entry to be retained")
+	}
+
+	// A new key is not created for the entries (encrypted with the key).
+	if err = os.Rename(key, key+".bak"); err != nil {
+		t.Fatalf("error: unable to move the key file: %s", err)
+	}
+
+	if _, err = openJournal(dir); err == nil {
+		t.Fatalf("expected a missing key file (with entries) to fail")
+	}
+
+	if err = os.Rename(key+".bak", key); err != nil {
+		t.Fatalf("error: unable to restore the key file: %s", err)
+	}
+
+	// A different key cannot read the journal.
+	os.Setenv(journalKeyEnv, "stub-key")
+	defer os.Unsetenv(journalKeyEnv)
+
+	if jrn, err = openJournal(dir); err != nil {
+		t.Fatalf("error: unable to open the journal: %s", err)
+	}
+
+	if _, err = jrn.entries(); err == nil {
+		t.Fatalf("expected reading with a different key to fail")
+	}
+
+	// Completed rotations are removed.
+	e.Phase = jrnPersisted
+	e.finish()
+
+	paths, _ := filepath.Glob(filepath.Join(dir, "*"+journalExt))
+	if len(paths) != 0 {
+		t.Fatalf("expected the journal to be empty, got: %v", paths)
+	}
+
+	// Without a journal, the entry is only kept in memory.
+	if e, err = (*journal)(nil).begin("stub", "stub", ""); err != nil {
+		t.Fatalf("error: unable to begin an entry: %s", err)
+	}
+
+	if err = e.advance(jrnSubmitted); err != nil || e.path != "" {
+		t.Fatalf("unexpected entry (without a journal): %+v (%v)", e, err)
+	}
+	e.finish()
+}
@@ -3,21 +3,56 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 	"time"
 
 	"golang.org/x/crypto/ssh/terminal"
 
-	"github.com/chromedp/chromedp"
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
 	"github.com/fatih/color"
 	"github.com/sethvargo/go-password/password"
 )
 
 func main() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		sig    = make(chan os.Signal, 1)
+		err    error
+	)
+
 	flag.Usage = usage
+
+	// Interrupts cancel the browser flow (instead of killing the process),
+	// so that everything is cleaned up, and the journal is kept up to date.
+	ctx, cancel = context.WithCancel(context.Background())
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+
+	err = run(ctx)
+	cancel()
+
+	if err != nil {
+		errColor(os.Stderr, "ERR: %s\n", err)
+	}
+
+	if summary.out != nil {
+		summary.emit(summary.out, err)
+	}
+
+	os.Exit(rotate.ExitStatus(err))
+}
+
+// run runs the command; the error it returns decides the exit status.
+func run(ctx context.Context) error {
 	var (
 		// Things for command line arguments.
 		username = flag.String(
@@ -75,9 +110,65 @@ func main() {
 		)
 		timeout = flag.Uint(
 			"wait",
-			(10 * netflixVerifyWait),
+			uint(rotate.DefaultTimeout/time.Second),
 			"Time to wait for the operation to complete.",
 		)
+		cfgFile = flag.String(
+			"config", "", "Path to the configuration file (JSON).",
+		)
+		baseURL = flag.String(
+			"base-url", "", "Base URL for Netflix.",
+		)
+		loginPath = flag.String(
+			"login-path", "", "Path to the login page.",
+		)
+		passwordPath = flag.String(
+			"password-path", "", "Path to the password page.",
+		)
+		signoutPath = flag.String(
+			"signout-path", "", "Path to the sign-out page.",
+		)
+		selFile = flag.String(
+			"selectors", "", "Path to the selector profile (JSON/YAML).",
+		)
+		flowFile = flag.String(
+			"flow", "", "Path to the flow document (JSON/YAML).",
+		)
+		debugDir = flag.String(
+			"debug-dir", "", "Capture the page on failures, in here.",
+		)
+		harFile = flag.String(
+			"har", "", "Record the network traffic (HAR) in here.",
+		)
+		remoteURL = flag.String(
+			"remote-debugging-url", "",
+			"Attach to a running browser (DevTools).",
+		)
+		headful = flag.Bool(
+			"headful", false, "Show the browser, pause on unknown pages.",
+		)
+		codeFile = flag.String(
+			"code-file", "", "Read the verification code from this file.",
+		)
+		codeExec = flag.String(
+			"code-exec", "", "Run this command for the verification code.",
+		)
+		profile = flag.String(
+			"profile", "", "Profile to pick on the profile gate.",
+		)
+		debug  = flag.Bool("verbose", false, "Print debug messages.")
+		outFmt = flag.String(
+			"output", outputText, "Output format (text or json).",
+		)
+		jrnDir = flag.String(
+			"journal", "", "Directory for the rotation journal.",
+		)
+		site = flag.String(
+			"site", rotate.SiteNetflix, "Site to rotate the password on.",
+		)
+		verifyNew = flag.Bool(
+			"verify-new", false, "Login with the new password, once updated.",
+		)
 		test = flag.Bool("test", false, "For testing only.")
 
 		// Things for interactive inputs.
@@ -86,44 +177,148 @@ func main() {
 		newPwInt    bool
 		overrideInt bool
 
-		rdr *bufio.Reader
-		wtr *bufio.Writer
+		rdr stdin
 
-		// Things for the browser.
-		err     error
-		eval    bool
-		evalStr string
-		tasks   chromedp.Tasks
-
-		execCtx context.Context
-		bwsrCtx context.Context
-		waitCtx context.Context
-
-		execCancel context.CancelFunc
-		bwsrCancel context.CancelFunc
-		waitCancel context.CancelFunc
-
-		login  = &netflixLogin{}
-		update = &netflixPasswordUpdate{}
+		// Things for the rotation.
+		err  error
+		opts rotate.Options
+		rot  *rotate.Rotator
+		res  *rotate.Result
 
 		// Misc.
+		title  string
+		cmd    string
+		cfg    *config
 		tmp    []byte
 		pword  *password.Generator
-		errno  *int
-		status int
-		pwFile *os.File
+		pwFile *pwFile
+		jrn    *journal
+		entry  *journalEntry
 	)
 
-	errno = &status
-	defer exit(errno)
+	// The subcommand (if any) comes before the options.
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		cmd = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
 
 	flag.Parse()
 
-	rdr = bufio.NewReader(os.Stdin)
+	rdr = stdin{bufio.NewReader(os.Stdin)}
 
 	if *noColor {
 		color.NoColor = true
 	}
+	rotate.Verbose = *debug
+
+	// For the JSON output, standard output is reserved for the summary;
+	// everything else goes to standard error.
+	switch *outFmt {
+	case outputText:
+	case outputJSON:
+		summary.out, os.Stdout = os.Stdout, os.Stderr
+
+		defer func() {
+			summary.Command = cmd
+			summary.Username = *username
+			if entry != nil {
+				summary.Phase = entry.Phase
+			}
+		}()
+	default:
+		return rotate.NewError(
+			rotate.PhaseSetup, rotate.KindFlagFail, nil,
+			fmt.Sprintf("Unknown output format: \"%s\"", *outFmt),
+		)
+	}
+
+	cfg, err = loadConfig(*cfgFile)
+	if err != nil {
+		return rotate.NewError(
+			rotate.PhaseSetup, rotate.KindFlagFail, err,
+			"Unable to load the configuration file",
+		)
+	}
+
+	opts = rotate.Options{
+		Site:         *site,
+		Username:     *username,
+		OldPassword:  *oldPassword,
+		DevLogout:    *devLogout,
+		VerifyNew:    *verifyNew,
+		BaseURL:      rotate.Pick(*baseURL, cfg.BaseURL),
+		LoginPath:    rotate.Pick(*loginPath, cfg.LoginPath),
+		PasswordPath: rotate.Pick(*passwordPath, cfg.PasswordPath),
+		SignoutPath:  rotate.Pick(*signoutPath, cfg.SignoutPath),
+		TmpDir:       *tmpDir,
+		DebugDir:     *debugDir,
+		HAR:          *harFile,
+		ExecPath:     *execPath,
+		RemoteURL:    rotate.Pick(*remoteURL, cfg.RemoteURL),
+		Headful:      *headful,
+		Input:        rdr,
+		CodeFile:     *codeFile,
+		CodeExec:     *codeExec,
+		Profile:      *profile,
+		Timeout:      time.Duration(*timeout) * time.Second,
+	}
+
+	opts.Selectors, err = rotate.LoadSelectors(
+		rotate.Pick(*selFile, cfg.Selectors),
+	)
+	if err != nil {
+		return rotate.NewError(
+			rotate.PhaseSetup, rotate.KindFlagFail, err,
+			"Unable to load the selector profile",
+		)
+	}
+
+	opts.Flow, err = rotate.LoadFlow(rotate.Pick(*flowFile, cfg.Flow))
+	if err != nil {
+		return rotate.NewError(
+			rotate.PhaseSetup, rotate.KindFlagFail, err,
+			"Unable to load the flow document",
+		)
+	}
+
+	// Without it in the environment, the PIN of a locked profile is prompted
+	// for on the input (without the echo), if it is a terminal.
+	if pin, ok := os.LookupEnv(profilePINEnv); ok {
+		opts.PIN = func() (string, error) { return pin, nil }
+	}
+
+	// Validate the options before prompting for anything.
+	if rot, err = rotate.New(opts); err != nil {
+		return err
+	}
+	title = strings.Title(rot.Site())
+
+	switch cmd {
+	case "":
+	case cmdDoctor:
+		return runDoctor(ctx, rot)
+	case cmdRecover:
+		return runRecover(ctx, *jrnDir, opts)
+	case cmdFlow:
+		fmt.Print(rotate.NetflixFlow)
+		return nil
+	case cmdSignout:
+		// The password is not changed; only the login is prompted for.
+		if *updatePassword != "" || *autoGeneratePassword || *outFile != "" {
+			wrnColor(
+				os.Stderr,
+				"WRN: The password is not changed by `%s';"+
+					" ignoring the options for the new password.\n",
+				cmdSignout,
+			)
+		}
+		*updatePassword, *autoGeneratePassword = "", false
+	default:
+		return rotate.NewError(
+			rotate.PhaseSetup, rotate.KindFlagFail, nil,
+			fmt.Sprintf("Unknown command: \"%s\"", cmd),
+		)
+	}
 
 	if *username == "" {
 		usrInt = true
@@ -133,7 +328,7 @@ func main() {
 		oldPwInt = true
 	}
 
-	if *updatePassword == "" {
+	if *updatePassword == "" && cmd != cmdSignout {
 		newPwInt = true
 	}
 
@@ -159,10 +354,9 @@ func main() {
 			})
 		}
 		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to initialize the password generator (%s).\n",
-				err,
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindAutoFail, err,
+				"Unable to initialize the password generator",
 			)
 		}
 
@@ -174,249 +368,218 @@ func main() {
 			*autoGenerateAllowRepeat,
 		)
 		if err != nil {
-			// Fallback to interactive input.
-			overrideInt = false
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindAutoFail, err,
+				"Unable to auto-generate a new password",
+			)
+		}
 
-			errColor(
+		if *outFile == "" {
+			infColor(
 				os.Stderr,
-				"ERR: Unable to auto-generate a new password (%s).\n",
-				err,
+				"INF: Generated Password: \"%s\" "+
+					"(does not include the encolsing quotes).\n",
+				*updatePassword,
 			)
-			*errno = errAutoFail
-			return
-		} else {
-			if *outFile == "" {
-				infColor(
-					os.Stderr,
-					"INF: Generated Password: \"%s\" "+
-						"(does not include the encolsing quotes).\n",
-					*updatePassword,
-				)
-			}
 		}
 		overrideInt = true
 	}
 
 	if usrInt {
-		inpColor(os.Stdout, "Netflix Username: ")
+		inpColor(os.Stdout, "%s Username: ", title)
 		*username, err = rdr.ReadString('\n')
 		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to read the input string (%s).\n",
-				err,
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindFlagFail, err,
+				"Unable to read the input string",
 			)
-
-			*errno = errFlagFail
-			return
 		}
 		*username = strings.TrimSpace(*username)
 	}
 
 	if oldPwInt {
-		inpColor(os.Stdout, "Netflix Password (for %s, current): ", *username)
+		inpColor(
+			os.Stdout, "%s Password (for %s, current): ", title, *username,
+		)
 		tmp, err = terminal.ReadPassword(int(os.Stdin.Fd()))
 		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to read the input string (%s).\n",
-				err,
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindFlagFail, err,
+				"Unable to read the input string",
 			)
-
-			*errno = errFlagFail
-			return
 		}
 		*oldPassword = string(tmp)
 		fmt.Println()
 	}
 
+	if cmd == cmdSignout {
+		return runSignout(ctx, opts, *username, *oldPassword, title)
+	}
+
 	if !overrideInt && newPwInt {
-		inpColor(os.Stdout, "Netflix Password (for %s, updated): ", *username)
+		inpColor(
+			os.Stdout, "%s Password (for %s, updated): ", title, *username,
+		)
 		tmp, err = terminal.ReadPassword(int(os.Stdin.Fd()))
 		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to read the input string (%s).\n",
-				err,
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindFlagFail, err,
+				"Unable to read the input string",
 			)
-
-			*errno = errFlagFail
-			return
 		}
 		*updatePassword = string(tmp)
 		fmt.Println()
 
-		inpColor(os.Stdout, "Netflix Password (for %s, confirm): ", *username)
+		inpColor(
+			os.Stdout, "%s Password (for %s, confirm): ", title, *username,
+		)
 		tmp, err = terminal.ReadPassword(int(os.Stdin.Fd()))
 		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to read the input string (%s).\n",
-				err,
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindFlagFail, err,
+				"Unable to read the input string",
 			)
-			*errno = errFlagFail
-			return
 		}
 		fmt.Println()
 
 		if string(tmp) != *updatePassword {
-			errColor(os.Stderr, "ERR: Passwords do not match.\n")
-			*errno = errFlagFail
-			return
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindFlagFail, nil,
+				"Passwords do not match",
+			)
 		}
 	}
 
-	// Create a temporary directory for user data.
-	*tmpDir, err = mkTmpDir(*tmpDir)
+	// Open the output file before starting the browser,
+	// so that the new password can always be written.
+	if *outFile != "" {
+		pwFile, err = openPwFile(*outFile)
+		if err != nil {
+			return rotate.NewError(
+				rotate.PhaseSetup, rotate.KindWriteFail, err,
+				"Unable to open file for writing",
+			)
+		}
+		defer pwFile.close()
+	}
+
+	// Record the rotation in the journal, so that it can be recovered
+	// if it is interrupted; without one, the rotation goes on regardless.
+	if jrn, err = openJournal(*jrnDir); err == nil {
+		entry, err = jrn.begin(*username, *updatePassword, *outFile)
+	}
 	if err != nil {
-		errColor(
+		wrnColor(
 			os.Stderr,
-			"ERR: Unable to create a temporary directory (%s).\n",
+			"WRN: Unable to write the journal (%s); the rotation cannot be "+
+				"recovered if it is interrupted.\n",
 			err,
 		)
-
-		*errno = errTmpFail
-		return
+		entry, _ = (*journal)(nil).begin(
+			*username, *updatePassword, *outFile,
+		)
 	}
-	defer os.RemoveAll(*tmpDir)
+	defer entry.finish()
 
-	// Create an execution alloator.
-	execCtx, execCancel = genExecContext(*tmpDir, *execPath)
-	defer execCancel()
-
-	// Add a wait context for timeouts.
-	waitCtx, waitCancel = context.WithTimeout(
-		execCtx, (time.Duration(*timeout) * time.Second),
-	)
-	defer waitCancel()
+	opts.Username = *username
+	opts.OldPassword = *oldPassword
+	opts.NewPassword = *updatePassword
+	opts.Progress = progress(entry, pwFile, *updatePassword)
 
-	// This is the main context for the browser.
-	bwsrCtx, bwsrCancel = chromedp.NewContext(waitCtx)
-	defer bwsrCancel()
-
-	// Get the login credentials.
-	login.loadLoginParams(*username, *oldPassword)
-
-	// Get the list of actions for login.
-	tasks = loginActions(login)
-
-	// Login to Netflix.
-	err = exec(bwsrCtx, tasks)
-	if err != nil {
-		errColor(os.Stderr, "ERR: Browser execution failed (%s).\n", err)
-
-		*errno = errExecFail
-		return
+	if rot, err = rotate.New(opts); err != nil {
+		return err
 	}
 
-	// Check if the login works.
-	evalStr, eval = getFailureReason(bwsrCtx, "login")
-	if eval {
-		errColor(os.Stderr, "ERR: %s\n", evalStr)
-
-		*errno = errVerifyFail
-		return
-	} else {
-		eval, err = jsEval(
-			bwsrCtx, fmt.Sprintf(netflixEval, login.evalXpath, " === null"),
-		)
-		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Netflix login verification failed (%s).\n",
-				err,
-			)
-
-			*errno = errVerifyFail
-			return
-		}
-		if !eval {
-			errColor(os.Stderr, "ERR: Netflix login failed.\n")
-
-			*errno = errLoginFail
-			return
-		}
+	res, err = rot.Rotate(ctx)
+	for _, phase := range res.Phases {
+		summary.add(phase)
 	}
+	summary.DevicesSignedOut = res.DevicesSignedOut
 
-	// Get the update credentials.
-	update.loadUpdateParams(*oldPassword, *updatePassword, *devLogout)
-
-	// Get the list of actions for update.
-	tasks = updateActions(update)
-
-	// Update the password.
-	err = exec(bwsrCtx, tasks)
 	if err != nil {
-		errColor(os.Stderr, "ERR: Browser execution failed (%s).\n", err)
+		switch {
+		case entry.Phase != jrnSubmitted:
+		case errors.Is(err, rotate.KindStateOld), rejected(res):
+			// The update was turned down, or the old password still works.
+			entry.discard = true
+		case pwFile != nil:
+			// The new password may be live; the entry is kept (for recover).
+			pwFile.fallback(*updatePassword)
+		}
 
-		*errno = errExecFail
-		return
+		return err
 	}
 
-	// Check if the update worked.
-	evalStr, eval = getFailureReason(bwsrCtx, "update")
-	if eval {
-		errColor(os.Stderr, "ERR: %s\n", evalStr)
+	okColor(
+		os.Stdout, "INF: The password for %s was updated successfully!\n", title,
+	)
 
-		*errno = errVerifyFail
-		return
-	} else {
-		eval, err = jsEval(
-			bwsrCtx, fmt.Sprintf(netflixEval, update.evalXpath, " !== null"),
-		)
-		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Netflix password update verification failed (%s).\n",
-				err,
-			)
+	return nil
+}
 
-			*errno = errVerifyFail
-			return
-		}
-		if !eval {
-			errColor(os.Stderr, "ERR: Password update failed.\n")
+// stdin is the (buffered) standard input, which keeps its file descriptor,
+// so that the prompts on it can tell if it is a terminal.
+type stdin struct {
+	*bufio.Reader
+}
 
-			*errno = errUpdateFail
-			return
+// Fd returns the file descriptor of the standard input.
+func (stdin) Fd() uintptr {
+	return os.Stdin.Fd()
+}
+
+// rejected reports if the update was turned down, i.e., if it ended on
+// a failure message (and not on an unknown outcome).
+func rejected(res *rotate.Result) bool {
+	for i := len(res.Phases) - 1; i >= 0; i-- {
+		if res.Phases[i].Phase == rotate.PhaseUpdate {
+			return res.Phases[i].State == rotate.StateError
 		}
 	}
 
-	// Write the new password to a file.
-	if *outFile != "" {
-		infColor(
-			os.Stdout, "INF: Writing the new password to: \"%s\".\n", *outFile,
-		)
-		pwFile, err = os.Create(*outFile)
-		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to open file for writing (%s).\n",
-				err,
-			)
+	return false
+}
 
-			*errno = errWriteFail
-			return
-		}
-		defer pwFile.Close()
+// progress returns a hook for the steps of the rotation, which records
+// them in the journal, and writes the new password to the file (if any)
+// once it is live.
+func progress(
+	entry *journalEntry, f *pwFile, password string,
+) func(string) error {
+	return func(step string) error {
+		switch step {
+		case rotate.StepLoggedIn:
+			entry.mark(jrnLoggedIn)
+		case rotate.StepSubmitting:
+			// Record the update as submitted (with the new password),
+			// before submitting it.
+			if err := entry.advance(jrnSubmitted); err != nil {
+				return rotate.NewError(
+					rotate.PhaseUpdate, rotate.KindWriteFail, err,
+					"Unable to update the journal",
+				)
+			}
+		case rotate.StepUpdated:
+			entry.mark(jrnVerified)
 
-		wtr = bufio.NewWriter(pwFile)
-		_, err = wtr.WriteString(*updatePassword + "\n")
-		if err != nil {
-			errColor(
-				os.Stderr,
-				"ERR: Unable to write password to file (%s)\n",
-				err,
-			)
+			// Write the new password to a file.
+			if f != nil {
+				infColor(
+					os.Stdout,
+					"INF: Writing the new password to: \"%s\".\n", entry.OutFile,
+				)
+				if err := f.commit(password); err != nil {
+					f.fallback(password)
+					return rotate.NewError(
+						rotate.PhasePersist, rotate.KindWriteFail, err,
+						"Unable to write password to file",
+					)
+				}
+			}
 
-			*errno = errWriteFail
-			return
+			entry.mark(jrnPersisted)
 		}
-		wtr.Flush()
-	}
 
-	okColor(
-		os.Stdout,
-		"INF: The password for Netflix was updated successfully!\n",
-	)
+		return nil
+	}
 }
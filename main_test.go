@@ -13,6 +13,8 @@ import (
 	"testing"
 
 	"golang.org/x/crypto/sha3"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/internal/nflxmock"
 )
 
 const (
@@ -20,6 +22,9 @@ const (
 	credPath   = "test-data/netflix"
 	testPwPath = "nflx-pw-test"
 	testKeyEnv = "TEST_KEY"
+
+	// Path to the `google-chrome' binary (optional).
+	testExecEnv = "TEST_EXEC_PATH"
 )
 
 // nflxCreds has the credentials for test logins.
@@ -63,8 +68,29 @@ var (
 	tmpDir      string
 	prevPword   string
 	login       nflxEnc
+
+	// Run the tests against a local mock (if `TEST_KEY' is not set).
+	mock bool
 )
 
+// Credentials for the tests against the mock.
+var mockLogin = nflxEnc{
+	Netflix: nflxCreds{
+		UsernameFix: "stub@example.com",
+		PasswordOld: "stub-password-old",
+		PasswordNew: "stub-password-new",
+	},
+}
+
+// Binaries to look for, for running the tests against the mock.
+var browsers = []string{
+	"google-chrome",
+	"google-chrome-stable",
+	"chromium",
+	"chromium-browser",
+	"headless_shell",
+}
+
 // All the test cases go here.
 var CmdTests = []execParams{
 	execParams{
@@ -86,7 +112,7 @@ var CmdTests = []execParams{
 			"-no-color",
 		},
 		output:   "ERR: Your password must contain between 4 and 60 characters.",
-		status:   2,
+		status:   19,
 		unameIdx: 1,
 		comment:  "Test a bad password.",
 	},
@@ -99,7 +125,7 @@ var CmdTests = []execParams{
 			"-test",
 		},
 		output:  "ERR: Please enter a valid email.",
-		status:  2,
+		status:  15,
 		comment: "Test a bad email address.",
 	},
 	execParams{
@@ -111,7 +137,7 @@ var CmdTests = []execParams{
 			"-test",
 		},
 		output:  "ERR: Please enter a valid phone number.",
-		status:  2,
+		status:  16,
 		comment: "Test a bad phone number.",
 	},
 	execParams{
@@ -124,7 +150,7 @@ var CmdTests = []execParams{
 		},
 		output: "ERR: Incorrect password. " +
 			"Please try again or you can reset your password.",
-		status:   2,
+		status:   17,
 		unameIdx: 1,
 		comment:  "Test an invalid password.",
 	},
@@ -138,7 +164,7 @@ var CmdTests = []execParams{
 		},
 		output: "ERR: Sorry, you cannot use a previous password. " +
 			"Please try another password.",
-		status:   2,
+		status:   18,
 		unameIdx: 1,
 		oldPwIdx: 3,
 		newPwIdx: 5,
@@ -196,6 +222,57 @@ var CmdTests = []execParams{
 		prevPword: true,
 		comment:   "Test reset success (with password from file).",
 	},
+	execParams{
+		flags: []string{
+			"recover",
+			"-no-color",
+		},
+		output:  "INF: Nothing to recover.",
+		status:  0,
+		comment: "Test recovering (with every rotation complete).",
+	},
+	execParams{
+		flags: []string{
+			"doctor",
+			"-username", "stub",
+			"-old-password", "stub",
+			"-no-color",
+		},
+		output:   "INF: All the selectors were found.",
+		status:   0,
+		unameIdx: 2,
+		oldPwIdx: 4,
+		comment:  "Test the selectors (doctor).",
+	},
+	execParams{
+		flags: []string{
+			"signout-devices",
+			"-username", "stub",
+			"-old-password", "stub",
+			"-no-color",
+		},
+		output:   "INF: All the devices were signed out of Netflix!",
+		status:   0,
+		unameIdx: 2,
+		oldPwIdx: 4,
+		comment:  "Test signing out of all devices (signout-devices).",
+	},
+	execParams{
+		flags: []string{
+			"-username", "stub",
+			"-old-password", "stub",
+			"-new-password", "stub",
+			"-no-color",
+			"-verify-new",
+			"-test",
+		},
+		output:   "INF: The password for Netflix was updated successfully!",
+		status:   0,
+		unameIdx: 1,
+		oldPwIdx: 3,
+		newPwIdx: 5,
+		comment:  "Test reset success (and login with the new password).",
+	},
 }
 
 // getPath gets the paths of files under this directory.
@@ -301,6 +378,27 @@ func readPass(path string) ([]byte, error) {
 	return ioutil.ReadFile(path)
 }
 
+// findBrowser finds the path to a browser for running the tests.
+func findBrowser() (string, bool) {
+	var (
+		path string
+		name string
+		err  error
+	)
+
+	if path = os.Getenv(testExecEnv); path != "" {
+		return path, true
+	}
+
+	for _, name = range browsers {
+		if path, err = exec.LookPath(name); err == nil {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
 // exCmd executes a the binary.
 func exCmd(p execParams) (*exec.Cmd, []byte, error) {
 	var (
@@ -310,6 +408,10 @@ func exCmd(p execParams) (*exec.Cmd, []byte, error) {
 	)
 
 	cmd = exec.Command(binary, p.flags...)
+	cmd.Env = append(
+		os.Environ(),
+		"NETFLIX_JOURNAL_KEY_FILE="+filepath.Join(tmpDir, "journal.key"),
+	)
 	out, err = cmd.CombinedOutput()
 
 	return cmd, out, err
@@ -332,9 +434,12 @@ func init() {
 		log.Fatalf("path: unable to find credentials: %s\n", err)
 	}
 
+	// Without a test key, run the tests against the mock.
 	pword = os.Getenv(testKeyEnv)
 	if pword == "" {
-		log.Fatalf("env: unable to load test key\n")
+		login = mockLogin
+		mock = true
+		return
 	}
 
 	key = getKey([]byte(pword))
@@ -354,14 +459,40 @@ func TestMain(t *testing.T) {
 		exErr *exec.ExitError
 		ok    bool
 		tmp   []byte
+		extra []string
+		path  string
+		srv   *nflxmock.Server
 	)
 
+	if _, err = os.Stat(binary); err != nil {
+		t.Skipf("path: unable to find the binary: %s", err)
+	}
+
+	if mock {
+		if path, ok = findBrowser(); !ok {
+			t.Skipf("path: unable to find a browser to run the tests with")
+		}
+
+		srv = nflxmock.NewServer(
+			login.Netflix.UsernameFix, login.Netflix.PasswordOld,
+		)
+		defer srv.Close()
+
+		extra = []string{
+			"-base-url", srv.URL,
+			"-exec-path", path,
+		}
+	}
+
 	tmpDir, err = ioutil.TempDir("", testPwPath)
 	if err != nil {
 		t.Fatalf("error: unable to create a temporary directory: %s", err)
 	}
 	defer os.RemoveAll(tmpDir)
 
+	// Keep the journal out of the home directory.
+	extra = append(extra, "-journal", filepath.Join(tmpDir, "journal"))
+
 	for _, test = range CmdTests {
 		// Some values need to be assigned during runtime.
 		if test.unameIdx != 0 {
@@ -391,6 +522,9 @@ func TestMain(t *testing.T) {
 			test.file = filepath.Join(tmpDir, "pw-file")
 		}
 
+		// Point the CLI to the mock.
+		test.flags = append(test.flags, extra...)
+
 		_, tmp, err = exCmd(test)
 		if err != nil {
 			if exErr, ok = err.(*exec.ExitError); ok {
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// Output formats.
+const (
+	outputText = "text" // Colored messages.
+	outputJSON = "json" // A single JSON object (on standard output).
+)
+
+// summary is the result of the run; the timings are recorded as the phases
+// are reported.
+var summary = &runSummary{Timings: []phaseTiming{}}
+
+// runSummary is the result of a run, for the JSON output. This never
+// includes any of the passwords.
+type runSummary struct {
+	Command          string        `json:"command"`
+	Username         string        `json:"username,omitempty"`
+	Phase            string        `json:"phase,omitempty"`
+	Status           int           `json:"status"`
+	Exit             string        `json:"exit"`
+	Reason           string        `json:"reason,omitempty"`
+	Kind             string        `json:"kind,omitempty"`
+	Timings          []phaseTiming `json:"timings"`
+	DevicesSignedOut bool          `json:"devices_signed_out"`
+
+	out io.Writer // Where to write the summary (nil for text output).
+}
+
+// phaseTiming is how long a phase (of the browser flow) took.
+type phaseTiming struct {
+	Phase   string  `json:"phase"`
+	State   string  `json:"state"`
+	Seconds float64 `json:"seconds"`
+}
+
+// add records the timing of a phase.
+func (s *runSummary) add(r *rotate.PhaseResult) {
+	s.Timings = append(s.Timings, phaseTiming{
+		Phase:   r.Phase,
+		State:   r.State,
+		Seconds: r.Elapsed.Round(time.Millisecond).Seconds(),
+	})
+}
+
+// emit writes the summary (as JSON) for the error the run ended with.
+func (s *runSummary) emit(w io.Writer, err error) error {
+	var (
+		enc  = json.NewEncoder(w)
+		kind = rotate.KindOf(err)
+	)
+
+	s.Status, s.Exit, s.Reason, s.Kind = 0, "ok", "", ""
+	if kind != nil {
+		s.Status, s.Exit = kind.Status(), kind.Exit()
+		s.Reason, s.Kind = err.Error(), kind.Name()
+	}
+
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// TestSummary tests the JSON output.
+func TestSummary(t *testing.T) {
+	var (
+		buf bytes.Buffer
+		out map[string]interface{}
+		s   = &runSummary{Username: "stub", Timings: []phaseTiming{}}
+		err error
+	)
+
+	s.add(&rotate.PhaseResult{
+		Phase:   rotate.PhaseLogin,
+		State:   rotate.StatePassword,
+		Elapsed: 1234 * time.Millisecond,
+	})
+	err = rotate.NewError(
+		rotate.PhaseLogin, rotate.KindIncorrectPass, nil, "Incorrect password.",
+	)
+	if err = s.emit(&buf, err); err != nil {
+		t.Fatalf("error: unable to emit the summary: %s", err)
+	}
+
+	if err = json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("error: bad JSON: %s", err)
+	}
+
+	for key, want := range map[string]interface{}{
+		"username": "stub",
+		"status":   float64(rotate.KindIncorrectPass.Status()),
+		"exit":     "errPasswdFail",
+		"reason":   "Incorrect password.",
+		"kind":     "incorrect-password",
+	} {
+		if out[key] != want {
+			t.Fatalf("%s:\n\twant:\t%v\n\tgot:\t%v\n", key, want, out[key])
+		}
+	}
+
+	timing := out["timings"].([]interface{})[0].(map[string]interface{})
+	if timing["phase"] != rotate.PhaseLogin || timing["seconds"] != 1.234 {
+		t.Fatalf("unexpected timing: %v", timing)
+	}
+
+	// The reason is dropped on success.
+	buf.Reset()
+	err = s.emit(&buf, nil)
+	if err != nil || bytes.Contains(buf.Bytes(), []byte("reason")) {
+		t.Fatalf("expected no reason on success: %s (%v)", buf.String(), err)
+	}
+}
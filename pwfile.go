@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// pwFile is the output file for the new password. It is opened before the
+// browser is started, so that problems with it show up before the update;
+// the new password is also held in the journal until it is written.
+type pwFile struct {
+	path string
+	file *os.File
+}
+
+// openPwFile opens (or creates) the output file, without truncating it.
+func openPwFile(path string) (*pwFile, error) {
+	var (
+		fi  os.FileInfo
+		f   = &pwFile{path: path}
+		err error
+	)
+
+	f.file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi, err = f.file.Stat(); err != nil {
+		f.file.Close()
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		f.file.Close()
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrInvalid}
+	}
+
+	return f, nil
+}
+
+// commit writes the new password to the output file.
+func (f *pwFile) commit(password string) error {
+	var err error
+
+	if err = f.file.Truncate(0); err != nil {
+		return err
+	}
+
+	if _, err = f.file.WriteAt([]byte(password+"\n"), 0); err != nil {
+		return err
+	}
+
+	return f.file.Sync()
+}
+
+// fallback prints the new password, if it could not be written to the
+// output file. The password is printed only if standard error is a terminal,
+// so that it does not end up in logs (it is still held in the journal).
+func (f *pwFile) fallback(password string) {
+	if terminal.IsTerminal(int(os.Stderr.Fd())) {
+		wrnColor(
+			os.Stderr,
+			"WRN: New Password: \"%s\" (does not include the enclosing quotes).\n",
+			password,
+		)
+	}
+}
+
+// close closes the output file.
+func (f *pwFile) close() {
+	f.file.Close()
+}
+
+// writeSync writes a file (readable only by the user), and syncs it to disk.
+func writeSync(path string, buf []byte) error {
+	var (
+		file *os.File
+		err  error
+	)
+
+	file, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(buf); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err = file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
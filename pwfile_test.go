@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestPwFile tests writing the new password to the output file.
+func TestPwFile(t *testing.T) {
+	var (
+		dir  string
+		path string
+		buf  []byte
+		f    *pwFile
+		err  error
+	)
+
+	dir, err = ioutil.TempDir("", "nflx-pw-test")
+	if err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if _, err = openPwFile(dir); err == nil {
+		t.Fatalf("expected opening a directory to fail")
+	}
+
+	path = filepath.Join(dir, "pw-file")
+	if err = ioutil.WriteFile(path, []byte("stub-password-old\n"), 0600); err != nil {
+		t.Fatalf("error: unable to write the file: %s", err)
+	}
+
+	// The file is not truncated until the new password is written.
+	if f, err = openPwFile(path); err != nil {
+		t.Fatalf("error: unable to open the file: %s", err)
+	}
+
+	if buf, _ = ioutil.ReadFile(path); string(buf) != "stub-password-old\n" {
+		t.Fatalf("expected the file to be retained, got: %q", buf)
+	}
+
+	if err = f.commit("stub-password-new"); err != nil {
+		t.Fatalf("error: unable to commit the password: %s", err)
+	}
+	f.close()
+
+	if buf, _ = ioutil.ReadFile(path); string(buf) != "stub-password-new\n" {
+		t.Fatalf("expected the new password in the file, got: %q", buf)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// runRecover finishes the incomplete rotations in the journal. For every
+// submitted rotation, logging in with the new password tells if the update
+// went through; if it did not, the old password (if given) is tried.
+// Verified rotations are persisted to their output files.
+// The options are shared by all the entries (but for the username).
+func runRecover(ctx context.Context, jrnDir string, opts rotate.Options) error {
+	var (
+		jrn     *journal
+		entries []*journalEntry
+		last    error
+		err     error
+	)
+
+	if jrn, err = openJournal(jrnDir); err != nil {
+		return rotate.NewError(
+			rotate.PhaseResolve, rotate.KindWriteFail, err,
+			"Unable to open the journal",
+		)
+	}
+
+	if entries, err = jrn.entries(); err != nil {
+		return rotate.NewError(
+			rotate.PhaseResolve, rotate.KindWriteFail, err,
+			"Unable to read the journal",
+		)
+	}
+
+	if len(entries) == 0 {
+		okColor(os.Stdout, "INF: Nothing to recover.\n")
+		return nil
+	}
+
+	for _, e := range entries {
+		infColor(
+			os.Stderr,
+			"INF: Recovering the rotation for \"%s\" "+
+				"(started: %s, phase: %s).\n",
+			e.Username, e.Started.Local().Format("2006-01-02 15:04:05"), e.Phase,
+		)
+
+		if err = recoverEntry(ctx, e, opts); err != nil {
+			errColor(os.Stderr, "ERR: %s\n", err)
+			last = err
+		}
+	}
+
+	if last != nil {
+		return rotate.NewError(
+			rotate.PhaseResolve, rotate.KindOf(last), nil,
+			"Some of the rotations could not be recovered",
+		)
+	}
+
+	okColor(os.Stdout, "INF: All the rotations were recovered.\n")
+	return nil
+}
+
+// recoverEntry recovers a single rotation.
+func recoverEntry(
+	ctx context.Context, e *journalEntry, opts rotate.Options,
+) error {
+	var (
+		r   *rotate.Rotator
+		res *rotate.PhaseResult
+		f   *pwFile
+		err error
+	)
+
+	defer e.finish()
+
+	switch e.Phase {
+	case jrnStarted, jrnLoggedIn:
+		infColor(os.Stderr, "INF: The update was never submitted.\n")
+		return nil
+	case jrnSubmitted:
+		opts.Username = e.Username
+		if r, err = rotate.New(opts); err != nil {
+			return err
+		}
+
+		res, err = r.Check(ctx, e.NewPassword)
+		summary.add(res)
+
+		if err != nil {
+			if opts.OldPassword == "" {
+				return rotate.NewError(
+					rotate.PhaseResolve, rotate.KindStateFail, nil,
+					"The new password does not work; "+
+						"retry with -old-password to check the old one",
+				)
+			}
+
+			res, err = r.Check(ctx, opts.OldPassword)
+			summary.add(res)
+
+			if err != nil {
+				return rotate.NewError(
+					rotate.PhaseResolve, rotate.KindStateFail, nil,
+					"State unresolved: neither password works",
+				)
+			}
+
+			infColor(
+				os.Stderr, "INF: State resolved: the old password works.\n",
+			)
+			e.discard = true
+			return nil
+		}
+
+		infColor(os.Stderr, "INF: State resolved: the new password works.\n")
+		if err = e.advance(jrnVerified); err != nil {
+			return rotate.NewError(
+				rotate.PhaseResolve, rotate.KindWriteFail, err,
+				"Unable to update the journal",
+			)
+		}
+	}
+
+	// The new password is live; write it out.
+	if e.OutFile != "" {
+		infColor(
+			os.Stdout, "INF: Writing the new password to: \"%s\".\n", e.OutFile,
+		)
+
+		if f, err = openPwFile(e.OutFile); err == nil {
+			err = f.commit(e.NewPassword)
+			f.close()
+		}
+
+		if err != nil {
+			return rotate.NewError(
+				rotate.PhasePersist, rotate.KindWriteFail, err,
+				"Unable to write password to file",
+			)
+		}
+	}
+
+	if err = e.advance(jrnPersisted); err != nil {
+		return rotate.NewError(
+			rotate.PhasePersist, rotate.KindWriteFail, err,
+			"Unable to update the journal",
+		)
+	}
+
+	return nil
+}
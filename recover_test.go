@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// stubSite is a site driver, which accepts a single password.
+type stubSite struct {
+	password string
+}
+
+func (d *stubSite) Name() string    { return "stub" }
+func (d *stubSite) BaseURL() string { return "http://stub.test" }
+
+func (d *stubSite) Login(
+	ctx context.Context, phase, username, password string,
+) (*rotate.PhaseResult, error) {
+	if password != d.password {
+		return &rotate.PhaseResult{Phase: phase, State: rotate.StateError}, nil
+	}
+
+	return &rotate.PhaseResult{Phase: phase, State: rotate.StatePassword}, nil
+}
+
+func (d *stubSite) ChangePassword(
+	ctx context.Context, old, new string, devLogout bool,
+) (*rotate.PhaseResult, bool, error) {
+	return nil, false, nil
+}
+
+func (d *stubSite) FailureReason(
+	ctx context.Context, phase string,
+) (*rotate.Error, bool) {
+	return nil, false
+}
+
+// stubBrowser is never driven (by the stub site).
+type stubBrowser struct {
+	rotate.Browser
+}
+
+// TestRecover tests finishing the incomplete rotations in the journal.
+func TestRecover(t *testing.T) {
+	var tests = []struct {
+		name     string
+		phase    string // The phase the rotation was left in.
+		password string // The password that works.
+		old      string // The old password (-old-password).
+		kind     *rotate.Kind
+		file     string // Expected contents of the output file.
+		kept     bool   // The entry is kept (for another recover).
+	}{
+		{name: "never submitted", phase: jrnLoggedIn, password: "old"},
+		{
+			name: "new password works", phase: jrnSubmitted, password: "new",
+			file: "new\n",
+		},
+		{
+			name: "old password works", phase: jrnSubmitted, password: "old",
+			old: "old",
+		},
+		{
+			name: "old password not given", phase: jrnSubmitted,
+			password: "old", kind: rotate.KindStateFail, kept: true,
+		},
+		{
+			name: "neither password works", phase: jrnSubmitted,
+			password: "other", old: "old", kind: rotate.KindStateFail,
+			kept: true,
+		},
+		{
+			name: "not persisted", phase: jrnVerified, password: "new",
+			file: "new\n",
+		},
+	}
+
+	var (
+		dir  string
+		site = &stubSite{}
+		err  error
+	)
+
+	dir, err = ioutil.TempDir("", "nflx-recover-test")
+	if err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	os.Setenv(journalKeyEnv, "stub-key")
+	defer os.Unsetenv(journalKeyEnv)
+
+	rotate.Register("stub", func(rotate.Options) (rotate.SiteDriver, error) {
+		return site, nil
+	})
+
+	for _, test := range tests {
+		var (
+			jrn   *journal
+			e     *journalEntry
+			buf   []byte
+			jdir  = filepath.Join(dir, test.name)
+			out   = filepath.Join(dir, test.name+".pw")
+			paths []string
+		)
+
+		if jrn, err = openJournal(jdir); err == nil {
+			e, err = jrn.begin("stub", "new", out)
+		}
+		if err == nil {
+			err = e.advance(test.phase)
+		}
+		if err != nil {
+			t.Fatalf("%s: unable to write the journal: %s", test.name, err)
+		}
+
+		site.password = test.password
+		err = runRecover(context.Background(), jdir, rotate.Options{
+			Site:        "stub",
+			OldPassword: test.old,
+			Browser:     stubBrowser{},
+		})
+		if rotate.KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, rotate.KindOf(err), err,
+			)
+		}
+
+		if buf, _ = ioutil.ReadFile(out); string(buf) != test.file {
+			t.Fatalf("%s: unexpected output file: %q", test.name, buf)
+		}
+
+		paths, _ = filepath.Glob(filepath.Join(jdir, "*"+journalExt))
+		if (len(paths) != 0) != test.kept {
+			t.Fatalf("%s: unexpected journal: %v", test.name, paths)
+		}
+	}
+}
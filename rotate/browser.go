@@ -0,0 +1,126 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Browser is the set of operations the browser flow is built on; elements
+// are located by XPath. The default is Chrome (through chromedp).
+type Browser interface {
+	// Navigate loads a URL.
+	Navigate(ctx context.Context, url string) error
+
+	// WaitVisible waits for an element to be visible.
+	WaitVisible(ctx context.Context, xpath string) error
+
+	// SendKeys types into an element.
+	SendKeys(ctx context.Context, xpath, keys string) error
+
+	// Click clicks on an element.
+	Click(ctx context.Context, xpath string) error
+
+	// Evaluate evaluates a JavaScript expression, into res.
+	Evaluate(ctx context.Context, expr string, res interface{}) error
+
+	// Text reads the (rendered) text of an element.
+	Text(ctx context.Context, xpath string) (string, error)
+
+	// Location reads the current URL.
+	Location(ctx context.Context) (string, error)
+}
+
+// browserKey is the context key for the browser.
+type browserKey struct{}
+
+// withBrowser returns a context for driving a browser.
+func withBrowser(ctx context.Context, b Browser) context.Context {
+	return context.WithValue(ctx, browserKey{}, b)
+}
+
+// browser returns the browser for a context; this is Chrome, unless
+// another one was set with withBrowser.
+func browser(ctx context.Context) Browser {
+	if b, ok := ctx.Value(browserKey{}).(Browser); ok {
+		return b
+	}
+
+	return chromeBrowser{}
+}
+
+// chromeBrowser drives Chrome (with chromedp); the browser is started on
+// the first operation, from the chromedp context (see genBrowserContext).
+type chromeBrowser struct{}
+
+// Navigate satisfies the Browser interface.
+func (chromeBrowser) Navigate(ctx context.Context, url string) error {
+	return run(ctx, chromedp.Navigate(url))
+}
+
+// WaitVisible satisfies the Browser interface.
+func (chromeBrowser) WaitVisible(ctx context.Context, xpath string) error {
+	return run(ctx, chromedp.WaitVisible(xpath))
+}
+
+// SendKeys satisfies the Browser interface.
+func (chromeBrowser) SendKeys(ctx context.Context, xpath, keys string) error {
+	return run(ctx, chromedp.SendKeys(xpath, keys))
+}
+
+// Click satisfies the Browser interface.
+func (chromeBrowser) Click(ctx context.Context, xpath string) error {
+	return run(ctx, chromedp.Click(xpath))
+}
+
+// Evaluate satisfies the Browser interface.
+func (chromeBrowser) Evaluate(
+	ctx context.Context, expr string, res interface{},
+) error {
+	return run(ctx, chromedp.Evaluate(expr, res))
+}
+
+// Text satisfies the Browser interface.
+func (b chromeBrowser) Text(ctx context.Context, xpath string) (string, error) {
+	var (
+		txt string
+		err error
+	)
+
+	err = b.Evaluate(ctx, fmt.Sprintf(netflixEval, xpath, ".innerText"), &txt)
+	return txt, err
+}
+
+// Location satisfies the Browser interface.
+func (chromeBrowser) Location(ctx context.Context) (string, error) {
+	var (
+		loc string
+		err error
+	)
+
+	err = run(ctx, chromedp.Location(&loc))
+	return loc, err
+}
+
+// run runs the actions on Chrome. The browser is started (on the first run)
+// from the context it was created with, and not from the one with the
+// timeout, so that the page can still be captured after a timeout.
+func run(ctx context.Context, actions ...chromedp.Action) error {
+	var c = chromedp.FromContext(ctx)
+
+	if c != nil && c.Target == nil {
+		if err := chromedp.Run(captureContext(ctx)); err != nil {
+			return err
+		}
+	}
+
+	return chromedp.Run(ctx, actions...)
+}
+
+// navigate returns an action, which loads a URL.
+func navigate(url string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		return browser(ctx).Navigate(ctx, url)
+	})
+}
@@ -0,0 +1,273 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+// fakeEval matches the expressions built from `netflixEval'.
+var fakeEval = regexp.MustCompile(
+	`(?s)document\.evaluate\(\s*("(?:[^"\\]|\\.)*").*\.singleNodeValue(.*)$`,
+)
+
+// fakePage is a scripted page state: the elements on it (XPath to text),
+// and what clicking on (or typing into) them leads to (the name of the
+// next page).
+type fakePage struct {
+	url    string
+	elems  map[string]string
+	clicks map[string]func(f *fakeBrowser) (string, error)
+	inputs map[string]func(f *fakeBrowser) (string, error)
+
+	// The page it moves on to once it is reported (i.e., its URL is read),
+	// as if the operator took care of it.
+	solved string
+}
+
+// fakeBrowser is an in-memory Browser, driven by scripted page states.
+type fakeBrowser struct {
+	pages map[string]*fakePage // Pages, by name.
+	urls  map[string]string    // Names of the pages, by URL.
+	page  string               // Name of the current page.
+	keys  map[string]string    // Keys typed on the current page, by XPath.
+}
+
+// newFakeBrowser creates a browser on a blank page.
+func newFakeBrowser() *fakeBrowser {
+	return &fakeBrowser{
+		pages: map[string]*fakePage{"blank": {url: "about:blank"}},
+		urls:  make(map[string]string),
+		page:  "blank",
+		keys:  make(map[string]string),
+	}
+}
+
+// add adds a page; pages with a URL are loaded by navigating to it.
+func (f *fakeBrowser) add(name string, p *fakePage) *fakePage {
+	if p.elems == nil {
+		p.elems = make(map[string]string)
+	}
+
+	if p.clicks == nil {
+		p.clicks = make(map[string]func(*fakeBrowser) (string, error))
+	}
+
+	if p.url != "" {
+		f.urls[p.url] = name
+	}
+
+	f.pages[name] = p
+	return p
+}
+
+// load switches to a page.
+func (f *fakeBrowser) load(name string) error {
+	if _, ok := f.pages[name]; !ok {
+		return fmt.Errorf("fake: no such page: %s", name)
+	}
+
+	f.page, f.keys = name, make(map[string]string)
+	return nil
+}
+
+// lookup finds an element on the current page.
+func (f *fakeBrowser) lookup(ctx context.Context, xpath string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if txt, ok := f.pages[f.page].elems[xpath]; ok {
+		return txt, nil
+	}
+
+	return "", fmt.Errorf("fake: not on %s: %s", f.page, xpath)
+}
+
+// Navigate satisfies the Browser interface.
+func (f *fakeBrowser) Navigate(ctx context.Context, url string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if name, ok := f.urls[url]; ok {
+		return f.load(name)
+	}
+
+	return fmt.Errorf("fake: no page at: %s", url)
+}
+
+// WaitVisible satisfies the Browser interface.
+func (f *fakeBrowser) WaitVisible(ctx context.Context, xpath string) error {
+	_, err := f.lookup(ctx, xpath)
+	return err
+}
+
+// SendKeys satisfies the Browser interface.
+func (f *fakeBrowser) SendKeys(ctx context.Context, xpath, keys string) error {
+	if _, err := f.lookup(ctx, xpath); err != nil {
+		return err
+	}
+
+	f.keys[xpath] += keys
+
+	if input, ok := f.pages[f.page].inputs[xpath]; ok {
+		next, err := input(f)
+		if next != "" {
+			f.load(next)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Click satisfies the Browser interface.
+func (f *fakeBrowser) Click(ctx context.Context, xpath string) error {
+	var (
+		next string
+		err  error
+	)
+
+	if _, err = f.lookup(ctx, xpath); err != nil {
+		return err
+	}
+
+	if click, ok := f.pages[f.page].clicks[xpath]; ok {
+		if next, err = click(f); next != "" {
+			f.load(next)
+		}
+	}
+
+	return err
+}
+
+// Evaluate satisfies the Browser interface; only the expressions built
+// from `netflixEval' are understood.
+func (f *fakeBrowser) Evaluate(
+	ctx context.Context, expr string, res interface{},
+) error {
+	var (
+		m     = fakeEval.FindStringSubmatch(expr)
+		xpath string
+		txt   string
+		err   error
+	)
+
+	if m == nil {
+		return fmt.Errorf("fake: unable to evaluate: %s", expr)
+	}
+
+	if xpath, err = strconv.Unquote(m[1]); err != nil {
+		return err
+	}
+
+	switch strings.TrimSpace(m[2]) {
+	case "!== null":
+		if err = ctx.Err(); err == nil {
+			*res.(*bool) = f.has(xpath)
+		}
+	case ".innerText":
+		if txt, err = f.lookup(ctx, xpath); err == nil {
+			*res.(*string) = txt
+		}
+	default:
+		err = fmt.Errorf("fake: unable to evaluate: %s", expr)
+	}
+
+	return err
+}
+
+// has reports if an element is on the current page.
+func (f *fakeBrowser) has(xpath string) bool {
+	_, ok := f.pages[f.page].elems[xpath]
+	return ok
+}
+
+// Text satisfies the Browser interface.
+func (f *fakeBrowser) Text(ctx context.Context, xpath string) (string, error) {
+	return f.lookup(ctx, xpath)
+}
+
+// Location satisfies the Browser interface.
+func (f *fakeBrowser) Location(ctx context.Context) (string, error) {
+	var p = f.pages[f.page]
+
+	if p.solved != "" {
+		defer f.load(p.solved)
+	}
+
+	return p.url, ctx.Err()
+}
+
+// Screenshot satisfies the Capturer interface.
+func (f *fakeBrowser) Screenshot(ctx context.Context) ([]byte, error) {
+	return []byte("fake: " + f.page), ctx.Err()
+}
+
+// DOM satisfies the Capturer interface; the elements are serialized in
+// the order of their XPaths.
+func (f *fakeBrowser) DOM(ctx context.Context) (string, error) {
+	var (
+		xps  []string
+		html = "<html>\n"
+	)
+
+	for xp := range f.pages[f.page].elems {
+		xps = append(xps, xp)
+	}
+	sort.Strings(xps)
+
+	for _, xp := range xps {
+		html += fmt.Sprintf(
+			"<div data-xpath=%q>%s</div>\n", xp, f.pages[f.page].elems[xp],
+		)
+	}
+
+	return html + "</html>\n", ctx.Err()
+}
+
+// Console satisfies the Capturer interface.
+func (f *fakeBrowser) Console(ctx context.Context) ([]string, error) {
+	return []string{"console.log: fake: on " + f.page}, ctx.Err()
+}
+
+// TestFakeBrowser tests the fake against the expressions of the flow.
+func TestFakeBrowser(t *testing.T) {
+	var (
+		ok  bool
+		txt string
+		ctx = withBrowser(context.Background(), newFakeBrowser())
+		f   = browser(ctx).(*fakeBrowser)
+		xp  = `//*[@id="it's \"quoted\""]`
+		err error
+	)
+
+	f.add("page", &fakePage{
+		url:   "http://fake.test/page",
+		elems: map[string]string{xp: "Some text."},
+	})
+
+	if ok, err = jsEval(ctx, fmt.Sprintf(netflixEval, xp, " !== null")); ok {
+		t.Fatalf("found an element on a blank page: %v", err)
+	}
+
+	err = exec(ctx, chromedp.Tasks{navigate("http://fake.test/page")})
+	if err != nil {
+		t.Fatalf("error: unable to navigate: %s", err)
+	}
+
+	if ok, err = jsEval(ctx, fmt.Sprintf(netflixEval, xp, " !== null")); !ok {
+		t.Fatalf("unable to find the element: %v", err)
+	}
+
+	if txt = extractText(ctx, xp); txt != "Some text." {
+		t.Fatalf("unexpected text: %q", txt)
+	}
+}
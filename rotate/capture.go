@@ -0,0 +1,283 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/emulation"
+	cdplog "github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/page"
+	cdpruntime "github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+)
+
+// redacted replaces the passwords in the captures.
+const redacted = "[REDACTED]"
+
+// Names of the files in a capture.
+const (
+	captureScreenshot = "screenshot.png"
+	captureDOM        = "dom.html"
+	captureConsole    = "console.log"
+	captureURL        = "url.txt"
+)
+
+// Capturer is implemented by the browsers that can capture the page, for
+// debugging failures (see Options.DebugDir).
+type Capturer interface {
+	// Screenshot captures the (full) page, as a PNG.
+	Screenshot(ctx context.Context) ([]byte, error)
+
+	// DOM serializes the page, with the values of the password fields
+	// redacted.
+	DOM(ctx context.Context) (string, error)
+
+	// Console returns the console messages (so far).
+	Console(ctx context.Context) ([]string, error)
+}
+
+// captureKey is the context key for the context the page is captured in.
+type captureKey struct{}
+
+// withCapture returns a context (for the flow), which carries the context
+// to capture the page in; the latter should outlive the timeout of the flow.
+func withCapture(ctx, cctx context.Context) context.Context {
+	return context.WithValue(ctx, captureKey{}, cctx)
+}
+
+// captureContext returns the context to capture the page in.
+func captureContext(ctx context.Context) context.Context {
+	if cctx, ok := ctx.Value(captureKey{}).(context.Context); ok {
+		return cctx
+	}
+
+	return ctx
+}
+
+// consoleKey is the context key for the console messages.
+type consoleKey struct{}
+
+// consoleLog collects the console messages of a browser.
+type consoleLog struct {
+	sync.Mutex
+	msgs []string
+}
+
+// listenConsole starts collecting the console messages (and uncaught
+// exceptions) of the browser; this is called before the browser is started.
+func listenConsole(ctx context.Context) context.Context {
+	var cl = &consoleLog{}
+
+	chromedp.ListenTarget(ctx, func(ev interface{}) {
+		var msg string
+
+		switch ev := ev.(type) {
+		case *cdpruntime.EventConsoleAPICalled:
+			var args []string
+			for _, arg := range ev.Args {
+				args = append(args, Pick(arg.Description, string(arg.Value)))
+			}
+			msg = fmt.Sprintf("console.%s: %s", ev.Type, strings.Join(args, " "))
+		case *cdpruntime.EventExceptionThrown:
+			msg = "exception: " + ev.ExceptionDetails.Text
+			if ev.ExceptionDetails.Exception != nil {
+				msg += " " + ev.ExceptionDetails.Exception.Description
+			}
+		case *cdplog.EventEntryAdded:
+			msg = fmt.Sprintf(
+				"log.%s: %s (%s)", ev.Entry.Level, ev.Entry.Text, ev.Entry.URL,
+			)
+		default:
+			return
+		}
+
+		cl.Lock()
+		cl.msgs = append(cl.msgs, msg)
+		cl.Unlock()
+	})
+
+	return context.WithValue(ctx, consoleKey{}, cl)
+}
+
+// Screenshot satisfies the Capturer interface.
+func (chromeBrowser) Screenshot(ctx context.Context) ([]byte, error) {
+	var buf []byte
+
+	err := run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var (
+			rect *dom.Rect
+			size *page.Viewport
+			err  error
+		)
+
+		if _, _, rect, err = page.GetLayoutMetrics().Do(ctx); err != nil {
+			return err
+		}
+
+		size = &page.Viewport{
+			X:      rect.X,
+			Y:      rect.Y,
+			Width:  rect.Width,
+			Height: rect.Height,
+			Scale:  1,
+		}
+
+		// Resize the viewport to the page, for a full-page screenshot.
+		err = emulation.SetDeviceMetricsOverride(
+			int64(math.Ceil(size.Width)), int64(math.Ceil(size.Height)),
+			1, false,
+		).Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		buf, err = page.CaptureScreenshot().WithClip(size).Do(ctx)
+		return err
+	}))
+
+	return buf, err
+}
+
+// DOM satisfies the Capturer interface.
+func (b chromeBrowser) DOM(ctx context.Context) (string, error) {
+	var (
+		html string
+		err  error
+	)
+
+	err = b.Evaluate(ctx, netflixDOM, &html)
+	return html, err
+}
+
+// Console satisfies the Capturer interface.
+func (chromeBrowser) Console(ctx context.Context) ([]string, error) {
+	var cl, ok = ctx.Value(consoleKey{}).(*consoleLog)
+
+	if !ok {
+		return nil, fmt.Errorf("the console messages were not collected")
+	}
+
+	cl.Lock()
+	defer cl.Unlock()
+
+	return append([]string{}, cl.msgs...), nil
+}
+
+// capture saves the state of the page (a screenshot, the DOM, the console
+// messages and the URL) into a new (timestamped) directory, under
+// Options.DebugDir. Whatever cannot be captured is skipped (with a warning).
+func (r *Rotator) capture(ctx context.Context, phase string) {
+	var (
+		dir    string
+		c      Capturer
+		ok     bool
+		cancel context.CancelFunc
+		err    error
+	)
+
+	if r.opts.DebugDir == "" {
+		return
+	}
+
+	ctx, cancel = context.WithTimeout(
+		captureContext(ctx), netflixCaptureWait*time.Second,
+	)
+	defer cancel()
+
+	if err = os.MkdirAll(r.opts.DebugDir, 0700); err == nil {
+		dir, err = ioutil.TempDir(
+			r.opts.DebugDir,
+			fmt.Sprintf("%s-%s-", time.Now().Format("20060102T150405"), phase),
+		)
+	}
+	if err != nil {
+		wrnColor(os.Stderr, "WRN: Unable to capture the page: %s.\n", err)
+		return
+	}
+
+	save := func(name string, fn func() ([]byte, error)) {
+		var (
+			buf []byte
+			err error
+		)
+
+		if buf, err = fn(); err == nil {
+			err = ioutil.WriteFile(filepath.Join(dir, name), buf, 0600)
+		}
+
+		if err != nil {
+			wrnColor(
+				os.Stderr, "WRN: Unable to capture the %s: %s.\n", name, err,
+			)
+		}
+	}
+
+	save(captureURL, func() ([]byte, error) {
+		loc, err := browser(ctx).Location(ctx)
+		return []byte(r.redact(loc) + "\n"), err
+	})
+
+	if c, ok = browser(ctx).(Capturer); !ok {
+		wrnColor(
+			os.Stderr, "WRN: The browser cannot capture the page (only %s).\n",
+			captureURL,
+		)
+	} else {
+		save(captureScreenshot, func() ([]byte, error) {
+			return c.Screenshot(ctx)
+		})
+
+		save(captureDOM, func() ([]byte, error) {
+			html, err := c.DOM(ctx)
+			return []byte(r.redact(html)), err
+		})
+
+		save(captureConsole, func() ([]byte, error) {
+			msgs, err := c.Console(ctx)
+			if len(msgs) == 0 {
+				return nil, err
+			}
+			return []byte(r.redact(strings.Join(msgs, "\n")) + "\n"), err
+		})
+	}
+
+	infColor(os.Stderr, "INF: Saved the debug artifacts to: \"%s\".\n", dir)
+}
+
+// redact replaces the passwords (from the options), and the PINs and
+// verification codes entered so far, in a capture.
+func (r *Rotator) redact(s string) string {
+	var secrets = []string{r.opts.OldPassword, r.opts.NewPassword}
+
+	r.mu.Lock()
+	secrets = append(secrets, r.secrets...)
+	r.mu.Unlock()
+
+	for _, v := range secrets {
+		if v != "" {
+			s = strings.Replace(s, v, redacted, -1)
+		}
+	}
+
+	return s
+}
+
+// secret records a PIN (or a verification code) for redacting it, before
+// it is typed in; it passes on the value (and the error) it is given.
+func (r *Rotator) secret(v string, err error) (string, error) {
+	if err == nil && v != "" {
+		r.mu.Lock()
+		r.secrets = append(r.secrets, v)
+		r.mu.Unlock()
+	}
+
+	return v, err
+}
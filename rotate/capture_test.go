@@ -0,0 +1,73 @@
+package rotate
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestCapture tests capturing the page on a failure, with the passwords
+// redacted.
+func TestCapture(t *testing.T) {
+	var (
+		r    *Rotator
+		dir  string
+		dirs []string
+		buf  []byte
+		f    = newFakeBrowser()
+		err  error
+	)
+
+	if dir, err = ioutil.TempDir("", "capture"); err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err = New(Options{
+		Username:    "stub@example.com",
+		OldPassword: "bad",
+		NewPassword: "new",
+		BaseURL:     "http://fake.test",
+		LoginPath:   "/login",
+		Browser:     f,
+		DebugDir:    filepath.Join(dir, "debug"),
+	})
+	if err != nil {
+		t.Fatalf("error: unable to create a rotator: %s", err)
+	}
+
+	(&fakeAccount{password: "old"}).script(f, r)
+
+	// The failure page echoes the password back.
+	page := f.pages["login-error"]
+	page.url = "http://fake.test/login?password=bad"
+	page.elems[xp(r.site.(*netflixDriver).sel.Login.Password)] = "bad"
+
+	if _, err = r.Rotate(context.Background()); err == nil {
+		t.Fatalf("error: the login did not fail")
+	}
+
+	dirs, err = filepath.Glob(filepath.Join(dir, "debug", "*-login-*"))
+	if err != nil || len(dirs) != 1 {
+		t.Fatalf("unexpected captures: %v (%v)", dirs, err)
+	}
+
+	for name, want := range map[string]string{
+		captureURL:        "/login?password=" + redacted,
+		captureScreenshot: "fake: login-error",
+		captureDOM:        redacted,
+		captureConsole:    "console.log: fake: on login-error",
+	} {
+		if buf, err = ioutil.ReadFile(filepath.Join(dirs[0], name)); err != nil {
+			t.Fatalf("error: unable to read the capture: %s", err)
+		}
+
+		if !strings.Contains(string(buf), want) ||
+			strings.Contains(string(buf), "bad") {
+			t.Fatalf("%s: expected %q, got: %q", name, want, buf)
+		}
+	}
+}
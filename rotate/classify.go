@@ -0,0 +1,47 @@
+package rotate
+
+import (
+	"regexp"
+)
+
+// kindRules match the failure messages to the kinds; the first match wins.
+var kindRules = []struct {
+	kind *Kind
+	re   *regexp.Regexp
+}{
+	{KindInvalidEmail, regexp.MustCompile(`(?i)valid email`)},
+	{KindInvalidPhone, regexp.MustCompile(`(?i)valid phone`)},
+	{KindReusedPass, regexp.MustCompile(`(?i)previous password`)},
+	{KindPasswordLen, regexp.MustCompile(`(?i)between \d+ and \d+ characters`)},
+	{KindPasswordMismatch, regexp.MustCompile(`(?i)(must|do not|don't) match`)},
+	{KindIncorrectPass, regexp.MustCompile(
+		`(?i)(incorrect password|password is incorrect)`,
+	)},
+}
+
+// kindSelectors are the kinds implied by the selector a failure message was
+// found by, for when the message itself is not recognized.
+var kindSelectors = map[string]*Kind{
+	"update.old_password_err": KindIncorrectPass,
+	"update.cnf_password_err": KindPasswordMismatch,
+}
+
+// classify classifies a failure message (found by a selector) into an
+// error; unrecognized messages are `unclassified' (with the message
+// retained as is).
+func classify(phase, selector, message string) *Error {
+	var e = &Error{Phase: phase, Kind: KindUnclassified, Msg: message}
+
+	for _, rule := range kindRules {
+		if rule.re.MatchString(message) {
+			e.Kind = rule.kind
+			return e
+		}
+	}
+
+	if kind, ok := kindSelectors[selector]; ok {
+		e.Kind = kind
+	}
+
+	return e
+}
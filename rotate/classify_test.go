@@ -0,0 +1,36 @@
+package rotate
+
+import (
+	"testing"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/internal/nflxmock"
+)
+
+// TestClassify tests classifying the failure messages.
+func TestClassify(t *testing.T) {
+	var tests = []struct {
+		selector string
+		message  string
+		kind     *Kind
+	}{
+		{"login.username_err", nflxmock.ErrInvalidEmail, KindInvalidEmail},
+		{"login.username_err", nflxmock.ErrInvalidPhone, KindInvalidPhone},
+		{"login.password_err", nflxmock.ErrPasswordLen, KindPasswordLen},
+		{"login.fail_err", nflxmock.ErrIncorrectPass, KindIncorrectPass},
+		{"update.old_password_err", nflxmock.ErrCurrentPass, KindIncorrectPass},
+		{"update.new_password_err", nflxmock.ErrReusedPass, KindReusedPass},
+		{"update.cnf_password_err", "Passwords must match.", KindPasswordMismatch},
+		{"update.old_password_err", "Something else.", KindIncorrectPass},
+		{"login.fail_err", "Something went wrong.", KindUnclassified},
+	}
+
+	for _, test := range tests {
+		e := classify(PhaseLogin, test.selector, test.message)
+		if e.Kind != test.kind || e.Msg != test.message {
+			t.Fatalf(
+				"classify(%s, %q):\n\twant:\t%s\n\tgot:\t%s (%q)\n",
+				test.selector, test.message, test.kind, e.Kind, e.Msg,
+			)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+)
+
+// codeKey is the context key for getting the verification code.
+type codeKey struct{}
+
+// codeFunc gets the verification code, once the challenge shows up.
+type codeFunc func(ctx context.Context) (string, error)
+
+// withCode returns a context, which gets the verification code with the
+// given function.
+func withCode(ctx context.Context, fn codeFunc) context.Context {
+	return context.WithValue(ctx, codeKey{}, fn)
+}
+
+// codeSource returns the function to get the verification code with (nil
+// if there is none, and the challenge cannot be handled).
+func codeSource(ctx context.Context) codeFunc {
+	fn, _ := ctx.Value(codeKey{}).(codeFunc)
+	return fn
+}
+
+// hasCode reports if there is a source for the verification code.
+func (r *Rotator) hasCode() bool {
+	return r.opts.CodeExec != "" || r.opts.CodeFile != "" || r.opts.Input != nil
+}
+
+// code gets the verification code from the first of its sources: the output
+// of Options.CodeExec, Options.CodeFile, or a prompt on Options.Input. The
+// clock is stopped meanwhile, for up to `netflixCodeWait' seconds.
+func (r *Rotator) code(ctx context.Context, c *pauseClock) (string, error) {
+	var (
+		code   string
+		cancel context.CancelFunc
+		err    error
+	)
+
+	c.pause()
+	defer c.resume()
+
+	ctx, cancel = context.WithTimeout(ctx, netflixCodeWait*time.Second)
+	defer cancel()
+
+	switch {
+	case r.opts.CodeExec != "":
+		infColor(os.Stderr, "INF: Running the hook for the verification code.\n")
+		code, err = codeFromExec(ctx, r.opts.CodeExec, r.opts.Username)
+	case r.opts.CodeFile != "":
+		infColor(
+			os.Stderr,
+			"INF: Waiting for the verification code in: \"%s\".\n",
+			r.opts.CodeFile,
+		)
+		code, err = codeFromFile(ctx, r.opts.CodeFile)
+	default:
+		code, err = r.prompt(
+			ctx, "Verification code (for %s): ", r.opts.Username,
+		)
+	}
+
+	if err == nil && code == "" {
+		err = fmt.Errorf("the verification code is empty")
+	}
+
+	return code, err
+}
+
+// codeFromExec runs a (shell) command, which prints the verification code;
+// the username is passed on in the environment, as NETFLIX_USERNAME.
+func codeFromExec(
+	ctx context.Context, command, username string,
+) (string, error) {
+	var (
+		out []byte
+		cmd = osexec.CommandContext(ctx, "sh", "-c", command)
+		err error
+	)
+
+	cmd.Env = append(os.Environ(), "NETFLIX_USERNAME="+username)
+	cmd.Stderr = os.Stderr
+
+	if out, err = cmd.Output(); err != nil {
+		return "", fmt.Errorf("%s: %s", command, err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// codeFromFile polls for a file with the verification code, and removes it
+// once it is read (so that it is not used twice).
+func codeFromFile(ctx context.Context, path string) (string, error) {
+	var (
+		buf []byte
+		err error
+	)
+
+	for {
+		buf, err = ioutil.ReadFile(path)
+		switch {
+		case err == nil && strings.TrimSpace(string(buf)) != "":
+			return strings.TrimSpace(string(buf)), os.Remove(path)
+		case err != nil && !os.IsNotExist(err):
+			return "", err
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%s did not show up: %s", path, ctx.Err())
+		case <-time.After(netflixPollWait * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCode tests submitting the verification code, from each of its sources.
+func TestCode(t *testing.T) {
+	var (
+		dir string
+		err error
+	)
+
+	if dir, err = ioutil.TempDir("", "code"); err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var tests = []struct {
+		name string
+		opts Options
+		kind *Kind // Expected kind of error (nil for none).
+	}{
+		{
+			name: "prompt",
+			opts: Options{Input: strings.NewReader("\n 123456 \n")},
+		},
+		{
+			name: "exec",
+			opts: Options{CodeExec: `echo "${NETFLIX_USERNAME%@*}"`},
+		},
+		{
+			name: "file",
+			opts: Options{CodeFile: filepath.Join(dir, "code.txt")},
+		},
+		{
+			name: "no input",
+			opts: Options{Input: strings.NewReader("")},
+			kind: KindPageFail,
+		},
+		{
+			name: "failed hook",
+			opts: Options{CodeExec: "exit 1"},
+			kind: KindPageFail,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			r   *Rotator
+			res *Result
+			f   = newFakeBrowser()
+			e   *Error
+		)
+
+		test.opts.Username = "123456@example.com"
+		test.opts.OldPassword = "old"
+		test.opts.NewPassword = "new"
+		test.opts.BaseURL = "http://fake.test"
+		test.opts.LoginPath = "/login"
+		test.opts.Browser = f
+		test.opts.Timeout = 5 * time.Second
+
+		if r, err = New(test.opts); err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+
+		account := &fakeAccount{
+			password: "old", gate: "challenge", code: "123456",
+		}
+		account.script(f, r)
+
+		if test.opts.CodeFile != "" {
+			go func(path string) {
+				time.Sleep(2 * netflixPollWait * time.Millisecond)
+				ioutil.WriteFile(path, []byte("123456\n"), 0600)
+			}(test.opts.CodeFile)
+		}
+
+		res, err = r.Rotate(context.Background())
+		if KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, KindOf(err), err,
+			)
+		}
+
+		if test.kind == nil && !res.Updated {
+			t.Fatalf("%s: unexpected result: %+v", test.name, res)
+		}
+
+		if errors.As(err, &e) && !strings.Contains(e.Error(), StateChallenge) {
+			t.Fatalf("%s: unexpected error: %s", test.name, e)
+		}
+
+		if test.opts.CodeFile != "" {
+			if _, err = os.Stat(test.opts.CodeFile); !os.IsNotExist(err) {
+				t.Fatalf("%s: the code file was not removed: %v", test.name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package rotate
+
+const (
+	// netflixBaseURL is the default base URL for Netflix.
+	netflixBaseURL = "https://netflix.com"
+
+	// netflixLoginPath is the default path to the login page; if empty,
+	// the password page is loaded directly (which redirects to login).
+	netflixLoginPath = ""
+
+	// netflixPasswordPath is the default path to the password page.
+	netflixPasswordPath = "/password"
+
+	// netflixSignoutPath is the path to the page for signing out of all
+	// the devices (see Rotator.SignOutDevices).
+	netflixSignoutPath = "/manageaccountaccess"
+
+	// netflixMnt is the default base XPath for the page.
+	netflixMnt = `//*[@id="appMountPoint"]`
+
+	// netflixEval is a JavaScript expression to evaluate
+	// (the XPath is quoted, so it may contain either kind of quotes).
+	netflixEval = `
+	document.evaluate(
+		%q, document, null, XPathResult.FIRST_ORDERED_NODE_TYPE, null
+	).singleNodeValue%s
+	`
+
+	// netflixPhaseWait is the maximum number of seconds to wait for
+	// a phase (login or update) to reach an outcome, unless the flow
+	// sets its own (see withPhaseWait).
+	netflixPhaseWait = 20
+
+	// netflixPollWait is the interval (in milliseconds) for polling
+	// the page for selectors.
+	netflixPollWait = 250
+
+	// netflixSettleWait is the number of milliseconds an unknown page has
+	// to stay, before a headful flow pauses on it (so that it does not
+	// pause on the pages which are still loading).
+	netflixSettleWait = 1500
+
+	// netflixDoctorWait is the maximum number of seconds to wait for
+	// a page to render, before checking the selectors on it.
+	netflixDoctorWait = 10
+
+	// netflixCaptureWait is the maximum number of seconds to wait for
+	// the page to be captured (see Options.DebugDir).
+	netflixCaptureWait = 10
+
+	// netflixRemoteWait is the maximum number of seconds to wait for
+	// a remote browser to report its websocket URL.
+	netflixRemoteWait = 10
+
+	// netflixCodeWait is the maximum number of seconds to wait for
+	// a verification code (see Options.CodeFile).
+	netflixCodeWait = 300
+
+	// netflixDOM is a JavaScript expression, which serializes the DOM
+	// (with the values of the password fields redacted).
+	netflixDOM = `
+	(function() {
+		var root = document.documentElement.cloneNode(true);
+		root.querySelectorAll("input").forEach(function(e) {
+			var id = [e.type, e.name, e.id, e.autocomplete].join(" ");
+			if (/pass/i.test(id)) {
+				e.setAttribute("value", "[REDACTED]");
+			}
+		});
+		return "<!DOCTYPE html>\n" + root.outerHTML;
+	})()
+	`
+
+	// Errors.
+	errExecFail   = 1  // Browser task execution failed.
+	errVerifyFail = 2  // Verification failed.
+	errLoginFail  = 3  // Login failed.
+	errUpdateFail = 4  // Update failed.
+	errFlagFail   = 5  // CLI options parsing or user input failed.
+	errAutoFail   = 6  // Password generation failed.
+	errTmpFail    = 7  // Creation of temporary directory failed.
+	errWriteFail  = 8  // File I/O failures.
+	errDoctorFail = 9  // Selector checks failed (or drifted).
+	errPageFail   = 10 // Landed on an unexpected page.
+	errNewPwFail  = 11 // Update looked fine, but the new password failed.
+	errStateOld   = 12 // Update outcome was unknown; the old password works.
+	errStateNew   = 13 // Update outcome was unknown; the new password works.
+	errStateFail  = 14 // Update outcome was unknown; neither password works.
+
+	// Classified failures (see classify.go); unclassified ones are
+	// reported as errVerifyFail.
+	errEmailFail  = 15 // Invalid email address.
+	errPhoneFail  = 16 // Invalid phone number.
+	errPasswdFail = 17 // Incorrect (current) password.
+	errReusedFail = 18 // A previous password was reused.
+	errLengthFail = 19 // Bad password length.
+	errMatchFail  = 20 // The password confirmation does not match.
+)
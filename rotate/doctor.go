@@ -0,0 +1,265 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Results of checking a selector.
+const (
+	CheckPass  = "PASS"  // Found by the preferred strategy.
+	CheckDrift = "DRIFT" // Found by a fallback strategy.
+	CheckFail  = "FAIL"  // Not found.
+	CheckSkip  = "SKIP"  // Not checked.
+
+	// doctorParentDepth is the number of ancestors to look up,
+	// for selectors which are not displayed.
+	doctorParentDepth = 2
+)
+
+// SelectorCheck is the result of checking a selector.
+type SelectorCheck struct {
+	Name   string // Name of the selector (e.g., `login.username').
+	Status string // One of CheckPass, CheckDrift, CheckFail or CheckSkip.
+	Detail string // The strategy it was found by (or what was tried).
+}
+
+// doctorPage has the selectors to check on a page.
+type doctorPage struct {
+	// Selectors that must be on the page.
+	required []*selector
+
+	// Selectors that are only displayed on failures; these are checked
+	// by looking for their parent elements, if they are not on the page.
+	optional []*selector
+}
+
+// loginChecks returns the selectors to check on the login page.
+func (s *Selectors) loginChecks() doctorPage {
+	return doctorPage{
+		required: []*selector{
+			s.Login.Username,
+			s.Login.Password,
+			s.Login.Remember,
+			s.Login.Submit,
+		},
+		optional: []*selector{
+			s.Login.Eval,
+			s.Login.UsernameErr,
+			s.Login.PasswordErr,
+			s.Login.FailErr,
+		},
+	}
+}
+
+// updateChecks returns the selectors to check on the password page.
+func (s *Selectors) updateChecks() doctorPage {
+	return doctorPage{
+		required: []*selector{
+			s.Update.OldPassword,
+			s.Update.NewPassword,
+			s.Update.CnfPassword,
+			s.Update.Logout,
+			s.Update.Submit,
+		},
+		optional: []*selector{
+			s.Update.Eval,
+			s.Update.OldPasswordErr,
+			s.Update.NewPasswordErr,
+			s.Update.CnfPasswordErr,
+		},
+	}
+}
+
+// skip marks all the selectors on a page as skipped.
+func (p doctorPage) skip(reason string) []SelectorCheck {
+	var (
+		sel    *selector
+		checks []SelectorCheck
+	)
+
+	for _, sel = range append(p.required, p.optional...) {
+		checks = append(checks, SelectorCheck{sel.name, CheckSkip, reason})
+	}
+
+	return checks
+}
+
+// check checks every selector on the (current) page.
+func (p doctorPage) check(ctx context.Context) []SelectorCheck {
+	var (
+		sel    *selector
+		checks []SelectorCheck
+	)
+
+	// Wait for the page to render.
+	p.wait(ctx)
+
+	for _, sel = range p.required {
+		checks = append(checks, checkSelector(ctx, sel, false))
+	}
+
+	for _, sel = range p.optional {
+		checks = append(checks, checkSelector(ctx, sel, true))
+	}
+
+	return checks
+}
+
+// wait waits for any of the required selectors to be on the page.
+func (p doctorPage) wait(ctx context.Context) {
+	var (
+		sel      *selector
+		deadline = time.Now().Add(netflixDoctorWait * time.Second)
+	)
+
+	for time.Now().Before(deadline) {
+		for _, sel = range p.required {
+			if i, _ := sel.find(ctx); i >= 0 {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(netflixPollWait * time.Millisecond):
+		}
+	}
+}
+
+// checkSelector checks if a selector resolves on the page. For optional
+// selectors, the ancestors of the positional XPaths are looked up
+// instead, if the element is not displayed.
+func checkSelector(ctx context.Context, sel *selector, optional bool) SelectorCheck {
+	var (
+		i   int
+		st  strategy
+		xp  string
+		ok  bool
+		err error
+	)
+
+	i, err = sel.find(ctx)
+	switch {
+	case i == 0:
+		return SelectorCheck{sel.name, CheckPass, sel.chain[i].String()}
+	case i > 0:
+		return SelectorCheck{
+			sel.name, CheckDrift, sel.chain[i].String() + " (fallback)",
+		}
+	case err != nil:
+		return SelectorCheck{sel.name, CheckFail, err.Error()}
+	}
+
+	if optional {
+		for _, st = range sel.chain {
+			if st.kind != byXpath {
+				continue
+			}
+
+			xp = parentXpath(st.value)
+			for n := 0; xp != "" && n < doctorParentDepth; n++ {
+				ok, _ = jsEval(ctx, fmt.Sprintf(netflixEval, xp, " !== null"))
+				if ok {
+					return SelectorCheck{
+						sel.name, CheckPass, fmt.Sprintf("parent: xpath=%q", xp),
+					}
+				}
+				xp = parentXpath(xp)
+			}
+		}
+	}
+
+	return SelectorCheck{sel.name, CheckFail, "tried: " + sel.String()}
+}
+
+// doctor checks the selectors on the login page, and (if the credentials
+// are available) on the password page, without submitting the update. If
+// the login fails, it is reported as a failed check (named `login').
+func doctor(
+	ctx context.Context, d *netflixDriver, username, password string,
+) ([]SelectorCheck, error) {
+	var (
+		sel    = d.sel
+		checks []SelectorCheck
+		phase  *PhaseResult
+		reason string
+		err    error
+	)
+
+	err = exec(ctx, chromedp.Tasks{navigate(d.routes.loginURL())})
+	if err != nil {
+		return nil, err
+	}
+
+	checks = sel.loginChecks().check(ctx)
+
+	if username == "" || password == "" {
+		return append(
+			checks, sel.updateChecks().skip("no credentials to login with")...,
+		), nil
+	}
+
+	// Login to get to the password page; the login page is reloaded. With
+	// the credentials given, a failed login fails the checks as well.
+	failed := func(reason string) []SelectorCheck {
+		checks = append(checks, SelectorCheck{
+			PhaseLogin, CheckFail, "login failed: " + reason,
+		})
+		return append(checks, sel.updateChecks().skip("login failed")...)
+	}
+
+	if phase, err = d.Login(ctx, PhaseLogin, username, password); err != nil {
+		return failed(err.Error()), nil
+	}
+
+	if phase.State == StateError {
+		if f, ok := d.FailureReason(ctx, PhaseLogin); ok {
+			reason = f.Msg
+		}
+		return failed(Pick(reason, "N/A.")), nil
+	}
+
+	return append(checks, sel.updateChecks().check(ctx)...), nil
+}
+
+// Doctor checks the selectors on the login page, and (if the credentials
+// are in the options) on the password page, in a new browser. The update
+// is never submitted.
+func (r *Rotator) Doctor(ctx context.Context) ([]SelectorCheck, error) {
+	var (
+		sc     SelectorChecker
+		ok     bool
+		checks []SelectorCheck
+		cancel context.CancelFunc
+		err    error
+	)
+
+	if sc, ok = r.site.(SelectorChecker); !ok {
+		return nil, NewError(
+			PhaseDoctor, KindFlagFail, nil,
+			"The selectors for \""+r.site.Name()+"\" cannot be checked",
+		)
+	}
+
+	if ctx, cancel, err = r.newBrowser(ctx); err != nil {
+		return nil, NewError(
+			PhaseDoctor, KindTmpFail, err,
+			"Unable to create a temporary directory",
+		)
+	}
+	defer cancel()
+
+	checks, err = sc.CheckSelectors(ctx, r.opts.Username, r.opts.OldPassword)
+	if err != nil {
+		return nil, NewError(
+			PhaseDoctor, KindExecFail, err, "Browser execution failed",
+		)
+	}
+
+	return checks, nil
+}
@@ -0,0 +1,55 @@
+package rotate
+
+import (
+	"context"
+	"testing"
+)
+
+// TestDoctor tests checking the selectors on the login and password pages,
+// and that a failed login (with the credentials given) fails the checks.
+func TestDoctor(t *testing.T) {
+	var tests = []struct {
+		name     string
+		password string
+		failed   string // The check that fails (if any).
+	}{
+		{name: "logged in", password: "old"},
+		{name: "login failed", password: "bad", failed: PhaseLogin},
+	}
+
+	for _, test := range tests {
+		var (
+			r      *Rotator
+			checks []SelectorCheck
+			f      = newFakeBrowser()
+			failed string
+			err    error
+		)
+
+		r, err = New(Options{
+			Username:    "stub@example.com",
+			OldPassword: test.password,
+			BaseURL:     "http://fake.test",
+			LoginPath:   "/login",
+			Browser:     f,
+		})
+		if err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+		(&fakeAccount{password: "old"}).script(f, r)
+
+		if checks, err = r.Doctor(context.Background()); err != nil {
+			t.Fatalf("%s: unable to run the checks: %s", test.name, err)
+		}
+
+		for _, c := range checks {
+			if c.Status == CheckFail && c.Name == PhaseLogin {
+				failed = c.Name
+			}
+		}
+
+		if failed != test.failed {
+			t.Fatalf("%s: unexpected checks: %+v", test.name, checks)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+package rotate
+
+import "golang.org/x/sys/unix"
+
+// The requests to get and set the terminal attributes (see noEcho).
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
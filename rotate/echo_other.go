@@ -0,0 +1,11 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package rotate
+
+import "errors"
+
+// noEcho is not supported on this platform.
+func noEcho(fd int) (func(), error) {
+	return nil, errors.New("unable to turn off the echo on this platform")
+}
@@ -0,0 +1,30 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package rotate
+
+import "golang.org/x/sys/unix"
+
+// noEcho turns off the echo on a terminal (leaving it line-buffered, unlike
+// a raw terminal), so that what is typed on it is not shown; the returned
+// function turns it back on.
+func noEcho(fd int) (func(), error) {
+	var (
+		old, tio *unix.Termios
+		err      error
+	)
+
+	if old, err = unix.IoctlGetTermios(fd, ioctlReadTermios); err != nil {
+		return nil, err
+	}
+
+	tio = new(unix.Termios)
+	*tio = *old
+	tio.Lflag &^= unix.ECHO
+
+	if err = unix.IoctlSetTermios(fd, ioctlWriteTermios, tio); err != nil {
+		return nil, err
+	}
+
+	return func() { unix.IoctlSetTermios(fd, ioctlWriteTermios, old) }, nil
+}
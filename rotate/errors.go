@@ -0,0 +1,137 @@
+package rotate
+
+import (
+	"errors"
+	"strings"
+)
+
+// Kind is a kind of error; every kind maps to an exit status.
+// Kinds are comparable with errors.Is, e.g., errors.Is(err, KindLoginFail).
+type Kind struct {
+	name   string // Name of the kind (e.g., `login').
+	exit   string // Name of the exit status constant.
+	status int    // The exit status.
+}
+
+// Error satisfies the error interface.
+func (k *Kind) Error() string {
+	return k.name
+}
+
+// Name returns the name of the kind (e.g., `login').
+func (k *Kind) Name() string {
+	return k.name
+}
+
+// Exit returns the name of the exit status (e.g., `errLoginFail').
+func (k *Kind) Exit() string {
+	return k.exit
+}
+
+// Status returns the exit status.
+func (k *Kind) Status() int {
+	return k.status
+}
+
+// Kinds of errors; this is the (only) mapping to the exit status codes.
+var (
+	KindExecFail   = &Kind{"exec", "errExecFail", errExecFail}
+	KindLoginFail  = &Kind{"login", "errLoginFail", errLoginFail}
+	KindUpdateFail = &Kind{"update", "errUpdateFail", errUpdateFail}
+	KindFlagFail   = &Kind{"flag", "errFlagFail", errFlagFail}
+	KindAutoFail   = &Kind{"auto-generate", "errAutoFail", errAutoFail}
+	KindTmpFail    = &Kind{"tmp-dir", "errTmpFail", errTmpFail}
+	KindWriteFail  = &Kind{"write", "errWriteFail", errWriteFail}
+	KindDoctorFail = &Kind{"doctor", "errDoctorFail", errDoctorFail}
+	KindPageFail   = &Kind{"unexpected-page", "errPageFail", errPageFail}
+	KindNewPwFail  = &Kind{"new-password", "errNewPwFail", errNewPwFail}
+	KindStateOld   = &Kind{"state-old", "errStateOld", errStateOld}
+	KindStateNew   = &Kind{"state-new", "errStateNew", errStateNew}
+	KindStateFail  = &Kind{"state-unknown", "errStateFail", errStateFail}
+
+	// Classified failures (see classify.go).
+	KindUnclassified = &Kind{
+		"unclassified", "errVerifyFail", errVerifyFail,
+	}
+	KindInvalidEmail = &Kind{
+		"invalid-email", "errEmailFail", errEmailFail,
+	}
+	KindInvalidPhone = &Kind{
+		"invalid-phone", "errPhoneFail", errPhoneFail,
+	}
+	KindIncorrectPass = &Kind{
+		"incorrect-password", "errPasswdFail", errPasswdFail,
+	}
+	KindReusedPass = &Kind{
+		"reused-password", "errReusedFail", errReusedFail,
+	}
+	KindPasswordLen = &Kind{
+		"password-length", "errLengthFail", errLengthFail,
+	}
+	KindPasswordMismatch = &Kind{
+		"password-mismatch", "errMatchFail", errMatchFail,
+	}
+)
+
+// Error is an error from a phase of the rotation.
+type Error struct {
+	Phase string // Phase of the rotation (e.g., `login').
+	Kind  *Kind  // Kind of the error.
+	Msg   string // What failed.
+	Err   error  // The underlying error (if any).
+}
+
+// NewError creates an error for a phase.
+func NewError(phase string, kind *Kind, cause error, msg string) error {
+	return &Error{Phase: phase, Kind: kind, Msg: msg, Err: cause}
+}
+
+// Error satisfies the error interface.
+func (e *Error) Error() string {
+	var s = e.Msg
+
+	if e.Err != nil {
+		s += " (" + e.Err.Error() + ")"
+	}
+
+	if !strings.HasSuffix(s, ".") {
+		s += "."
+	}
+
+	return s
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports if the error is of the given kind.
+func (e *Error) Is(target error) bool {
+	return target == e.Kind
+}
+
+// KindOf returns the kind of an error (nil for no error); errors without
+// one are treated as browser execution failures.
+func KindOf(err error) *Kind {
+	var e *Error
+
+	if err == nil {
+		return nil
+	}
+
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+
+	return KindExecFail
+}
+
+// ExitStatus returns the exit status for an error.
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	return KindOf(err).status
+}
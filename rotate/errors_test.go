@@ -0,0 +1,48 @@
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// TestErrors tests matching the errors (and their exit status).
+func TestErrors(t *testing.T) {
+	var (
+		pe    = &pageError{state: StateUnknown, reason: "stub"}
+		cause = NewError(PhaseUpdate, KindPageFail, pe, "Unexpected page")
+		err   = fmt.Errorf("wrapped: %w", cause)
+		e     *Error
+		p     *pageError
+	)
+
+	if !errors.Is(err, KindPageFail) || errors.Is(err, KindLoginFail) {
+		t.Fatalf("errors.Is: unexpected kind: %s", KindOf(err))
+	}
+
+	if !errors.As(err, &e) || e.Phase != PhaseUpdate {
+		t.Fatalf("errors.As: unable to find the phase: %v", err)
+	}
+
+	if !errors.As(err, &p) || p != pe {
+		t.Fatalf("errors.As: unable to find the cause: %v", err)
+	}
+
+	for _, test := range []struct {
+		err    error
+		status int
+	}{
+		{nil, 0},
+		{err, errPageFail},
+		{errors.New("stub"), errExecFail},
+		{classify(PhaseLogin, "", "Incorrect password."), errPasswdFail},
+		{NewError(PhaseResolve, KindStateNew, nil, "stub"), errStateNew},
+	} {
+		if status := ExitStatus(test.err); status != test.status {
+			t.Fatalf(
+				"ExitStatus(%v):\n\twant:\t%d\n\tgot:\t%d\n",
+				test.err, test.status, status,
+			)
+		}
+	}
+}
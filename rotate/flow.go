@@ -0,0 +1,505 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/chromedp/chromedp"
+	"gopkg.in/yaml.v2"
+)
+
+// NetflixFlow is the built-in flow (YAML) for Netflix. The steps refer to the
+// selectors by name (see Selectors), and the values are templates, with the
+// fields of flowVars (e.g., `{{.OldPassword}}').
+const NetflixFlow = `# Run before the state machine starts.
+start:
+  - navigate: "{{.LoginURL}}"
+
+# Run on the login page.
+login:
+  # Wait for the input boxes to load, and key in the login credentials.
+  - wait-visible: login.username
+  - wait-visible: login.password
+  - send-keys: login.username
+    value: "{{.Username}}"
+  - send-keys: login.password
+    value: "{{.OldPassword}}"
+
+  # Click on the buttons (remember and submit).
+  - click: login.remember
+  - click: login.submit
+
+  # For getting login failure reasons.
+  - extract-text-on-error: login.username_err
+  - extract-text-on-error: login.password_err
+  - extract-text-on-error: login.fail_err
+
+# Run on the verification code page, once the code is read (see -code-file).
+challenge:
+  - wait-visible: challenge.code
+  - send-keys: challenge.code
+    value: "{{.Code}}"
+  - click: challenge.submit
+
+# Run on the PIN prompt, once a locked profile is picked (see -profile).
+profile:
+  - wait-visible: profiles.pin
+  - send-keys: profiles.pin
+    value: "{{.PIN}}"
+
+# Run on the password page.
+update:
+  # Wait for the input boxes to load, and key in the passwords.
+  - wait-visible: update.old_password
+  - wait-visible: update.new_password
+  - wait-visible: update.cnf_password
+  - send-keys: update.old_password
+    value: "{{.OldPassword}}"
+  - send-keys: update.new_password
+    value: "{{.NewPassword}}"
+  - send-keys: update.cnf_password
+    value: "{{.NewPassword}}"
+
+  # The box for logging out of all devices is checked by default.
+  - click: update.logout
+    if: "{{not .DevLogout}}"
+
+  # Once the click is attempted, the outcome of the update is unknown
+  # until it is confirmed.
+  - click: update.submit
+    submit: true
+
+  # For getting update failure reasons.
+  - extract-text-on-error: update.old_password_err
+  - extract-text-on-error: update.new_password_err
+  - extract-text-on-error: update.cnf_password_err
+
+# Run on the page for signing out of all devices (see signout-devices).
+signout:
+  - wait-visible: signout.submit
+  - click: signout.submit
+`
+
+// Step actions, as they are named in a flow.
+const (
+	stepNavigate     = "navigate"
+	stepWaitVisible  = "wait-visible"
+	stepSendKeys     = "send-keys"
+	stepClick        = "click"
+	stepAssert       = "assert-present"
+	stepExtractError = "extract-text-on-error"
+)
+
+// Flow is a browser flow, as lists of steps; these can be overridden with a
+// flow document (JSON or YAML). Any section missing from the document retains
+// the built-in steps.
+type Flow struct {
+	Start     []*Step `json:"start" yaml:"start"`         // Before the login page.
+	Login     []*Step `json:"login" yaml:"login"`         // On the login page.
+	Challenge []*Step `json:"challenge" yaml:"challenge"` // On the challenge page.
+	Profile   []*Step `json:"profile" yaml:"profile"`     // On the PIN prompt.
+	Update    []*Step `json:"update" yaml:"update"`       // On the password page.
+	Signout   []*Step `json:"signout" yaml:"signout"`     // On the sign-out page.
+}
+
+// Step is a single step of a flow; exactly one of the actions is set.
+type Step struct {
+	Navigate      string `json:"navigate,omitempty" yaml:"navigate,omitempty"`
+	WaitVisible   string `json:"wait-visible,omitempty" yaml:"wait-visible,omitempty"`
+	SendKeys      string `json:"send-keys,omitempty" yaml:"send-keys,omitempty"`
+	Click         string `json:"click,omitempty" yaml:"click,omitempty"`
+	AssertPresent string `json:"assert-present,omitempty" yaml:"assert-present,omitempty"`
+	ExtractText   string `json:"extract-text-on-error,omitempty" yaml:"extract-text-on-error,omitempty"`
+
+	Value  string `json:"value,omitempty" yaml:"value,omitempty"`   // Keys to send (a template).
+	If     string `json:"if,omitempty" yaml:"if,omitempty"`         // Run only if this is `true' (a template).
+	Submit bool   `json:"submit,omitempty" yaml:"submit,omitempty"` // The click submits the update.
+}
+
+// flowVars are the variables for the templates in a flow.
+type flowVars struct {
+	Username    string
+	OldPassword string
+	NewPassword string
+	Code        string // The verification code (see StateChallenge).
+	PIN         string // The PIN of the profile (see StateProfiles).
+	DevLogout   bool
+	LoginURL    string
+	PasswordURL string
+}
+
+// LoadFlow reads a flow document on top of the built-in flow. The selectors
+// are checked once the flow is used (see Options.Flow).
+func LoadFlow(path string) (*Flow, error) {
+	var (
+		buf []byte
+		dec *json.Decoder
+		f   = &Flow{}
+		doc = &Flow{}
+		err error
+	)
+
+	if err = yaml.UnmarshalStrict([]byte(NetflixFlow), f); err != nil {
+		return nil, err
+	}
+
+	if path != "" {
+		if buf, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.UnmarshalStrict(buf, doc)
+		default:
+			dec = json.NewDecoder(bytes.NewReader(buf))
+			dec.DisallowUnknownFields()
+			err = dec.Decode(doc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+
+		// The sections are replaced (not merged) by the document.
+		f.override(doc)
+	}
+
+	if err = f.validate(); err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// override replaces the sections of a flow, with the ones in a document.
+func (f *Flow) override(doc *Flow) {
+	if doc.Start != nil {
+		f.Start = doc.Start
+	}
+
+	if doc.Login != nil {
+		f.Login = doc.Login
+	}
+
+	if doc.Challenge != nil {
+		f.Challenge = doc.Challenge
+	}
+
+	if doc.Profile != nil {
+		f.Profile = doc.Profile
+	}
+
+	if doc.Update != nil {
+		f.Update = doc.Update
+	}
+
+	if doc.Signout != nil {
+		f.Signout = doc.Signout
+	}
+}
+
+// flowSections are the names of the sections of a flow, in order.
+var flowSections = []string{
+	"start", "login", "challenge", "profile", "update", "signout",
+}
+
+// sections returns the sections of a flow, by name.
+func (f *Flow) sections() map[string][]*Step {
+	return map[string][]*Step{
+		"start":     f.Start,
+		"login":     f.Login,
+		"challenge": f.Challenge,
+		"profile":   f.Profile,
+		"update":    f.Update,
+		"signout":   f.Signout,
+	}
+}
+
+// validate checks the actions, and the templates of every step. The update
+// needs exactly one click with `submit' (see ambiguous), and the other
+// sections none.
+func (f *Flow) validate() error {
+	var (
+		i     int
+		n     int
+		name  string
+		steps []*Step
+		err   error
+	)
+
+	for _, name = range flowSections {
+		steps, n = f.sections()[name], 0
+		for i = range steps {
+			if err = steps[i].validate(); err != nil {
+				return fmt.Errorf("step %s[%d]: %s", name, i, err)
+			}
+
+			if !steps[i].Submit {
+				continue
+			}
+
+			if name != "update" {
+				return fmt.Errorf(
+					"step %s[%d]: `submit' is only for the `update' section",
+					name, i,
+				)
+			}
+			n++
+		}
+
+		if name == "update" && n != 1 {
+			return fmt.Errorf(
+				"section update: expected a single click with `submit', got %d",
+				n,
+			)
+		}
+	}
+
+	return nil
+}
+
+// check checks that every selector the flow refers to exists.
+func (f *Flow) check(sel *Selectors) error {
+	var (
+		i      int
+		name   string
+		target string
+		steps  []*Step
+		ok     bool
+	)
+
+	for _, name = range flowSections {
+		steps = f.sections()[name]
+		for i = range steps {
+			if _, target = steps[i].action(); target == "" {
+				continue
+			}
+
+			if _, ok = sel.lookup(target); !ok {
+				return fmt.Errorf(
+					"step %s[%d]: unknown selector: `%s'", name, i, target,
+				)
+			}
+		}
+	}
+
+	return nil
+}
+
+// action returns the action of a step, and the selector it refers to
+// (if any).
+func (s *Step) action() (string, string) {
+	switch {
+	case s.Navigate != "":
+		return stepNavigate, ""
+	case s.WaitVisible != "":
+		return stepWaitVisible, s.WaitVisible
+	case s.SendKeys != "":
+		return stepSendKeys, s.SendKeys
+	case s.Click != "":
+		return stepClick, s.Click
+	case s.AssertPresent != "":
+		return stepAssert, s.AssertPresent
+	case s.ExtractText != "":
+		return stepExtractError, s.ExtractText
+	}
+
+	return "", ""
+}
+
+// validate checks that a step has exactly one action (with the options
+// for it), and that its templates parse.
+func (s *Step) validate() error {
+	var (
+		n    int
+		act  string
+		tmpl string
+		err  error
+	)
+
+	for _, v := range []string{
+		s.Navigate, s.WaitVisible, s.SendKeys,
+		s.Click, s.AssertPresent, s.ExtractText,
+	} {
+		if v != "" {
+			n++
+		}
+	}
+
+	if n != 1 {
+		return fmt.Errorf("expected a single action, got %d", n)
+	}
+
+	act, _ = s.action()
+	switch {
+	case s.Value != "" && act != stepSendKeys:
+		return fmt.Errorf("`value' is only for `%s'", stepSendKeys)
+	case s.Submit && act != stepClick:
+		return fmt.Errorf("`submit' is only for `%s'", stepClick)
+	case s.If != "" && act == stepExtractError:
+		return fmt.Errorf("`if' is not for `%s'", stepExtractError)
+	}
+
+	for _, tmpl = range []string{s.Navigate, s.Value, s.If} {
+		if _, err = newTemplate(tmpl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newTemplate parses a template in a step.
+func newTemplate(text string) (*template.Template, error) {
+	return template.New("step").Option("missingkey=error").Parse(text)
+}
+
+// render executes a template in a step.
+func render(text string, vars *flowVars) (string, error) {
+	var (
+		buf  bytes.Buffer
+		tmpl *template.Template
+		err  error
+	)
+
+	if tmpl, err = newTemplate(text); err != nil {
+		return "", err
+	}
+
+	if err = tmpl.Execute(&buf, vars); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// compile compiles the steps into tasks; the steps which do not apply (by
+// `if') are left out. `submitted' (if not nil) is set once a submitting click
+// is attempted. Every selector resolves to the same element across the steps.
+func compile(
+	steps []*Step, sel *Selectors, vars *flowVars, submitted *bool,
+) (chromedp.Tasks, error) {
+	var (
+		act    string
+		target string
+		cond   string
+		value  string
+		found  *selector
+		ok     bool
+		elem   *element
+		tasks  = chromedp.Tasks{}
+		elems  = make(map[string]*element)
+		err    error
+	)
+
+	for i, s := range steps {
+		if act, target = s.action(); act == stepExtractError {
+			continue
+		}
+
+		if s.If != "" {
+			if cond, err = render(s.If, vars); err != nil {
+				return nil, fmt.Errorf("step %d: %s", i, err)
+			}
+
+			if strings.TrimSpace(cond) != "true" {
+				continue
+			}
+		}
+
+		if target != "" {
+			if elem = elems[target]; elem == nil {
+				if found, ok = sel.lookup(target); !ok {
+					return nil, fmt.Errorf(
+						"step %d: unknown selector: `%s'", i, target,
+					)
+				}
+
+				elem = newElement(found)
+				elems[target] = elem
+			}
+		}
+
+		switch act {
+		case stepNavigate:
+			if value, err = render(s.Navigate, vars); err != nil {
+				return nil, fmt.Errorf("step %d: %s", i, err)
+			}
+			tasks = append(tasks, navigate(value))
+		case stepWaitVisible:
+			tasks = append(tasks, elem.resolve())
+		case stepSendKeys:
+			if value, err = render(s.Value, vars); err != nil {
+				return nil, fmt.Errorf("step %d: %s", i, err)
+			}
+			tasks = append(tasks, elem.sendKeys(value))
+		case stepClick:
+			if s.Submit && submitted != nil {
+				tasks = append(tasks, elem.resolve(), markSubmitted(submitted))
+			}
+			tasks = append(tasks, elem.click())
+		case stepAssert:
+			tasks = append(tasks, assertPresent(elem.sel))
+		}
+	}
+
+	return tasks, nil
+}
+
+// markSubmitted returns an action, which records that the update is about
+// to be submitted (see Submitting).
+func markSubmitted(submitted *bool) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := Submitting(ctx); err != nil {
+			return err
+		}
+
+		*submitted = true
+		return nil
+	})
+}
+
+// assertPresent returns an action, which fails if an element is not on
+// the page (without waiting for it).
+func assertPresent(sel *selector) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if _, ok, err := sel.match(ctx); !ok {
+			return fmt.Errorf(
+				"`%s' is not on the page (%s): %v", sel.name, sel, err,
+			)
+		}
+
+		return nil
+	})
+}
+
+// errorSelectors returns the selectors for the failure messages (by
+// `extract-text-on-error'), in the order of the steps.
+func errorSelectors(steps []*Step, sel *Selectors) []*selector {
+	var (
+		s    *selector
+		ok   bool
+		sels []*selector
+	)
+
+	for _, step := range steps {
+		if step.ExtractText == "" {
+			continue
+		}
+
+		if s, ok = sel.lookup(step.ExtractText); ok {
+			sels = append(sels, s)
+		}
+	}
+
+	return sels
+}
@@ -0,0 +1,236 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// flowParams is a struct for running flow document tests.
+type flowParams struct {
+	name   string // Name of the flow file.
+	doc    string // Contents of the flow.
+	output string // Expected error (substring), empty if none.
+
+	// The section to check, and its expected actions (if no error).
+	get  func(*Flow) []*Step
+	want string
+
+	comment string // What the test does.
+}
+
+// All the test cases go here.
+var FlowTests = []flowParams{
+	flowParams{
+		name:    "builtin.yaml",
+		doc:     "start:\n  - navigate: \"{{.PasswordURL}}\"\n",
+		get:     func(f *Flow) []*Step { return f.Login },
+		want:    "wait-visible wait-visible send-keys send-keys click click",
+		comment: "Test retaining the built-in sections.",
+	},
+	flowParams{
+		name: "patch.json",
+		doc: `{"login": [` +
+			`{"send-keys": "login.username", "value": "{{.Username}}"},` +
+			`{"assert-present": "login.password"},` +
+			`{"click": "login.submit", "if": "{{ne .Username \"\"}}"}]}`,
+		get:     func(f *Flow) []*Step { return f.Login },
+		want:    "send-keys assert-present click",
+		comment: "Test patching a section (JSON).",
+	},
+	flowParams{
+		name:    "typo.yaml",
+		doc:     "update:\n  - clik: update.submit\n",
+		output:  "not found",
+		comment: "Test an unknown action (YAML).",
+	},
+	flowParams{
+		name:    "typo.json",
+		doc:     `{"updat": []}`,
+		output:  "unknown field",
+		comment: "Test an unknown section (JSON).",
+	},
+	flowParams{
+		name:    "double.yaml",
+		doc:     "login:\n  - click: login.submit\n    wait-visible: login.submit\n",
+		output:  "step login[0]: expected a single action, got 2",
+		comment: "Test a step with two actions.",
+	},
+	flowParams{
+		name:    "value.yaml",
+		doc:     "login:\n  - click: login.submit\n    value: x\n",
+		output:  "step login[0]: `value' is only for `send-keys'",
+		comment: "Test an option on the wrong action.",
+	},
+	flowParams{
+		name:    "nosubmit.yaml",
+		doc:     "update:\n  - click: update.submit\n",
+		output:  "section update: expected a single click with `submit', got 0",
+		comment: "Test an update without a submitting click.",
+	},
+	flowParams{
+		name:    "submit.yaml",
+		doc:     "login:\n  - click: login.submit\n    submit: true\n",
+		output:  "step login[0]: `submit' is only for the `update' section",
+		comment: "Test a submitting click outside of the update.",
+	},
+	flowParams{
+		name: "template.yaml",
+		doc: "update:\n  - send-keys: update.old_password\n" +
+			"    value: \"{{.OldPassword\"\n",
+		output:  "step update[0]: template",
+		comment: "Test a bad template.",
+	},
+}
+
+// actions returns the actions of the steps.
+func actions(steps []*Step) string {
+	var acts []string
+
+	for _, s := range steps {
+		act, _ := s.action()
+		if act != stepExtractError {
+			acts = append(acts, act)
+		}
+	}
+
+	return strings.Join(acts, " ")
+}
+
+// TestLoadFlow tests loading the flow documents.
+func TestLoadFlow(t *testing.T) {
+	var (
+		dir  string
+		path string
+		f    *Flow
+		err  error
+	)
+
+	if dir, err = ioutil.TempDir("", "flow"); err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test := range FlowTests {
+		path = filepath.Join(dir, test.name)
+		if err = ioutil.WriteFile(path, []byte(test.doc), 0600); err != nil {
+			t.Fatalf("error: unable to write the flow: %s", err)
+		}
+
+		f, err = LoadFlow(path)
+		if test.output != "" {
+			if err == nil || !strings.Contains(err.Error(), test.output) {
+				t.Fatalf(
+					"%s: expected error %q, got: %v",
+					test.comment, test.output, err,
+				)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", test.comment, err)
+		}
+
+		if got := actions(test.get(f)); got != test.want {
+			t.Fatalf("%s: expected %q, got: %q", test.comment, test.want, got)
+		}
+	}
+}
+
+// TestFlowSelectors tests checking the selectors a flow refers to.
+func TestFlowSelectors(t *testing.T) {
+	var (
+		f   *Flow
+		err error
+	)
+
+	if f, err = LoadFlow(""); err != nil {
+		t.Fatalf("error: unable to load the built-in flow: %s", err)
+	}
+
+	f.Update[0].WaitVisible = "update.old_passwd"
+	if _, err = New(Options{Flow: f}); !errors.Is(err, KindFlagFail) ||
+		!strings.Contains(err.Error(), "unknown selector: `update.old_passwd'") {
+		t.Fatalf("New: unexpected error for an unknown selector: %v", err)
+	}
+}
+
+// TestCompile tests compiling the built-in flow (with the conditional
+// click), and running it on a fake browser.
+func TestCompile(t *testing.T) {
+	var (
+		r *Rotator
+		f *Flow
+		d *netflixDriver
+		b *fakeBrowser
+	)
+
+	for _, logout := range []bool{false, true} {
+		var (
+			submitted bool
+			clicked   bool
+			vars      = &flowVars{OldPassword: "old", NewPassword: "new"}
+			err       error
+		)
+
+		b, vars.DevLogout = newFakeBrowser(), logout
+		r, _ = New(Options{Browser: b})
+		d = r.site.(*netflixDriver)
+		f = d.flow
+
+		b.add("password", &fakePage{
+			url: d.routes.passwordURL(),
+			elems: map[string]string{
+				xp(d.sel.Update.OldPassword): "",
+				xp(d.sel.Update.NewPassword): "",
+				xp(d.sel.Update.CnfPassword): "",
+				xp(d.sel.Update.Logout):      "",
+				xp(d.sel.Update.Submit):      "",
+			},
+			clicks: map[string]func(*fakeBrowser) (string, error){
+				xp(d.sel.Update.Logout): func(*fakeBrowser) (string, error) {
+					clicked = true
+					return "", nil
+				},
+			},
+		})
+		b.load("password")
+
+		tasks, err := compile(f.Update, d.sel, vars, &submitted)
+		if err != nil {
+			t.Fatalf("error: unable to compile the flow: %s", err)
+		}
+
+		ctx := withBrowser(context.Background(), b)
+		if err = tasks.Do(ctx); err != nil {
+			t.Fatalf("error: unable to run the flow: %s", err)
+		}
+
+		switch {
+		case !submitted:
+			t.Fatalf("the update was not marked as submitted")
+		case clicked == logout:
+			t.Fatalf("logout %v: unexpected click on the logout box", logout)
+		case b.keys[xp(d.sel.Update.CnfPassword)] != "new":
+			t.Fatalf("unexpected keys: %v", b.keys)
+		}
+	}
+
+	// An assertion fails without waiting for the element.
+	tasks, err := compile(
+		[]*Step{{AssertPresent: "challenge.code"}}, d.sel, &flowVars{}, nil,
+	)
+	if err != nil {
+		t.Fatalf("error: unable to compile the assertion: %s", err)
+	}
+
+	err = tasks.Do(withBrowser(context.Background(), b))
+	if err == nil || !strings.Contains(err.Error(), "`challenge.code'") {
+		t.Fatalf("unexpected error for a missing element: %v", err)
+	}
+}
@@ -0,0 +1,396 @@
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/har"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// harVersion is the version of the HAR format written.
+const harVersion = "1.2"
+
+// harSensitive are the (lower-case) words in the names of the headers, the
+// query parameters and the form fields, whose values are scrubbed; e.g.,
+// `currentPassword' (`current', `password') or `Set-Cookie' (`set', `cookie').
+var harSensitive = map[string]bool{
+	"auth": true, "authorization": true, "cookie": true, "password": true,
+	"passwd": true, "pw": true, "pin": true, "token": true, "csrf": true,
+	"xsrf": true, "session": true, "secret": true, "email": true,
+	"phone": true, "otp": true,
+}
+
+// harSensitiveNames are the (lower-case, without separators) names, which
+// are scrubbed as a whole; their words are common in other names (e.g.,
+// `user' in `User-Agent').
+var harSensitiveNames = map[string]bool{
+	"user": true, "username": true, "userloginid": true, "login": true,
+	"loginid": true, "challengecode": true, "netflixid": true,
+	"securenetflixid": true,
+}
+
+// harEntry is a request (and its response), as seen on the network.
+type harEntry struct {
+	started time.Time         // Wall time of the request.
+	sent    time.Time         // Monotonic time of the request.
+	done    time.Time         // Monotonic time of the response (if any).
+	req     *network.Request  // The request.
+	resp    *network.Response // The response (if any).
+	size    float64           // Bytes received.
+	failure string            // Why the request failed (if it did).
+}
+
+// harRecorder records the network traffic of the browsers (through the
+// DevTools protocol), for writing a HAR file (see Options.HAR).
+type harRecorder struct {
+	sync.Mutex
+	entries []*harEntry
+	pending map[network.RequestID]*harEntry
+	redact  func(string) string // Redacts the secrets.
+}
+
+// newHARRecorder creates a recorder.
+func newHARRecorder(redact func(string) string) *harRecorder {
+	return &harRecorder{
+		pending: make(map[network.RequestID]*harEntry),
+		redact:  redact,
+	}
+}
+
+// listen starts recording the network traffic of a browser; this starts
+// the browser (from the context it was created with).
+func (h *harRecorder) listen(ctx context.Context) error {
+	ctx = captureContext(ctx)
+
+	chromedp.ListenTarget(ctx, h.handle)
+	return chromedp.Run(ctx, network.Enable())
+}
+
+// handle records a network event.
+func (h *harRecorder) handle(ev interface{}) {
+	h.Lock()
+	defer h.Unlock()
+
+	switch ev := ev.(type) {
+	case *network.EventRequestWillBeSent:
+		// A redirect is the response to the previous request (by ID).
+		if e, ok := h.pending[ev.RequestID]; ok && ev.RedirectResponse != nil {
+			e.resp, e.done = ev.RedirectResponse, monotonic(ev.Timestamp)
+		}
+
+		e := &harEntry{sent: monotonic(ev.Timestamp), req: ev.Request}
+		if ev.WallTime != nil {
+			e.started = ev.WallTime.Time()
+		}
+		h.entries = append(h.entries, e)
+		h.pending[ev.RequestID] = e
+	case *network.EventResponseReceived:
+		if e, ok := h.pending[ev.RequestID]; ok {
+			e.resp = ev.Response
+		}
+	case *network.EventLoadingFinished:
+		if e, ok := h.pending[ev.RequestID]; ok {
+			e.done, e.size = monotonic(ev.Timestamp), ev.EncodedDataLength
+			delete(h.pending, ev.RequestID)
+		}
+	case *network.EventLoadingFailed:
+		if e, ok := h.pending[ev.RequestID]; ok {
+			e.done, e.failure = monotonic(ev.Timestamp), ev.ErrorText
+			delete(h.pending, ev.RequestID)
+		}
+	}
+}
+
+// monotonic converts a (monotonic) timestamp, which may be missing.
+func monotonic(t *cdp.MonotonicTime) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return t.Time()
+}
+
+// header reads a header (by a case-insensitive name).
+func header(hdrs network.Headers, name string) string {
+	for k, v := range hdrs {
+		if s, ok := v.(string); ok && strings.EqualFold(k, name) {
+			return s
+		}
+	}
+
+	return ""
+}
+
+// write writes the recorded traffic into a HAR file.
+func (h *harRecorder) write(path string) error {
+	var (
+		buf []byte
+		err error
+	)
+
+	if buf, err = json.MarshalIndent(h.har(), "", "  "); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(buf, '\n'), 0600)
+}
+
+// har builds the HAR document (scrubbed) from the recorded traffic.
+func (h *harRecorder) har() *har.HAR {
+	var log = &har.Log{
+		Version: harVersion,
+		Creator: &har.Creator{Name: "netflix-passwd-rotate", Version: "devel"},
+		Entries: []*har.Entry{},
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	for _, e := range h.entries {
+		log.Entries = append(log.Entries, h.entry(e))
+	}
+
+	return &har.HAR{Log: log}
+}
+
+// entry converts a recorded request into a HAR entry.
+func (h *harRecorder) entry(e *harEntry) *har.Entry {
+	var (
+		elapsed float64
+		proto   = "HTTP/1.1"
+		out     = &har.Entry{
+			StartedDateTime: e.started.UTC().Format(time.RFC3339Nano),
+			Cache:           &har.Cache{},
+			Comment:         e.failure,
+		}
+	)
+
+	if e.done.IsZero() && e.failure == "" {
+		out.Comment = "no response"
+	} else if !e.done.IsZero() {
+		elapsed = float64(e.done.Sub(e.sent)) / float64(time.Millisecond)
+	}
+	out.Time = elapsed
+	out.Timings = &har.Timings{Send: 0, Wait: elapsed, Receive: 0}
+
+	if e.resp != nil && e.resp.Protocol != "" {
+		proto = strings.ToUpper(e.resp.Protocol)
+	}
+
+	out.Request = &har.Request{
+		Method:      e.req.Method,
+		URL:         h.scrubURL(e.req.URL),
+		HTTPVersion: proto,
+		Cookies:     []*har.Cookie{},
+		Headers:     h.headers(e.req.Headers),
+		QueryString: h.query(e.req.URL),
+		HeadersSize: -1,
+		BodySize:    int64(len(e.req.PostData)),
+	}
+
+	if e.req.HasPostData || e.req.PostData != "" {
+		out.Request.PostData = h.postData(e.req)
+	}
+
+	out.Response = &har.Response{
+		HTTPVersion: proto,
+		Cookies:     []*har.Cookie{},
+		Headers:     []*har.NameValuePair{},
+		Content:     &har.Content{Size: int64(e.size)},
+		HeadersSize: -1,
+		BodySize:    int64(e.size),
+	}
+
+	if e.resp != nil {
+		out.Response.Status = e.resp.Status
+		out.Response.StatusText = e.resp.StatusText
+		out.Response.Headers = h.headers(e.resp.Headers)
+		out.Response.Content.MimeType = e.resp.MimeType
+		out.ServerIPAddress = e.resp.RemoteIPAddress
+
+		if loc := header(e.resp.Headers, "Location"); loc != "" {
+			out.Response.RedirectURL = h.scrubURL(loc)
+		}
+	}
+
+	return out
+}
+
+// sensitive reports if a header (or a parameter) carries credentials, by
+// its name, or by any of the words in it.
+func sensitive(name string) bool {
+	var words = nameWords(name)
+
+	for _, w := range words {
+		if harSensitive[w] {
+			return true
+		}
+	}
+
+	return harSensitiveNames[strings.Join(words, "")]
+}
+
+// nameWords splits a name into (lower-case) words, at the separators and
+// at the camel case boundaries (e.g., `userLoginId' into `user', `login'
+// and `id').
+func nameWords(name string) []string {
+	var (
+		words []string
+		word  []rune
+		prev  rune
+	)
+
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+
+	for _, c := range name {
+		switch {
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			flush()
+		case unicode.IsUpper(c) && unicode.IsLower(prev):
+			flush()
+			word = append(word, c)
+		default:
+			word = append(word, c)
+		}
+		prev = c
+	}
+	flush()
+
+	return words
+}
+
+// scrub scrubs the value of a header (or a parameter).
+func (h *harRecorder) scrub(name, value string) string {
+	if sensitive(name) {
+		return redacted
+	}
+
+	return h.redact(value)
+}
+
+// headers converts the headers (sorted by name), scrubbing them.
+func (h *harRecorder) headers(hdrs network.Headers) []*har.NameValuePair {
+	var (
+		names []string
+		pairs = []*har.NameValuePair{}
+	)
+
+	for name := range hdrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, _ := hdrs[name].(string)
+		pairs = append(pairs, &har.NameValuePair{
+			Name: name, Value: h.scrub(name, value),
+		})
+	}
+
+	return pairs
+}
+
+// query converts the query string of a URL, scrubbing it.
+func (h *harRecorder) query(raw string) []*har.NameValuePair {
+	var (
+		u     *url.URL
+		keys  []string
+		pairs = []*har.NameValuePair{}
+		err   error
+	)
+
+	if u, err = url.Parse(raw); err != nil {
+		return pairs
+	}
+
+	q := u.Query()
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range q[k] {
+			pairs = append(pairs, &har.NameValuePair{
+				Name: k, Value: h.scrub(k, v),
+			})
+		}
+	}
+
+	return pairs
+}
+
+// scrubURL scrubs the query string of a URL.
+func (h *harRecorder) scrubURL(raw string) string {
+	var (
+		u   *url.URL
+		err error
+	)
+
+	if u, err = url.Parse(raw); err != nil || u.RawQuery == "" {
+		return h.redact(raw)
+	}
+
+	q := u.Query()
+	for k, vs := range q {
+		for i := range vs {
+			vs[i] = h.scrub(k, vs[i])
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	return h.redact(u.String())
+}
+
+// postData converts the body of a request, scrubbing it; only the form
+// fields are kept, with the values of the sensitive ones (and any secrets)
+// redacted (the bodies of any other type are dropped).
+func (h *harRecorder) postData(req *network.Request) *har.PostData {
+	var (
+		mime = header(req.Headers, "Content-Type")
+		data = &har.PostData{MimeType: mime, Params: []*har.Param{}}
+		form url.Values
+		keys []string
+		err  error
+	)
+
+	if !strings.HasPrefix(mime, "application/x-www-form-urlencoded") {
+		data.Text = redacted
+		return data
+	}
+
+	if form, err = url.ParseQuery(req.PostData); err != nil {
+		data.Text = redacted
+		return data
+	}
+
+	for k := range form {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range form[k] {
+			data.Params = append(data.Params, &har.Param{
+				Name: k, Value: h.scrub(k, v),
+			})
+		}
+	}
+
+	return data
+}
@@ -0,0 +1,142 @@
+package rotate
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/har"
+	"github.com/chromedp/cdproto/network"
+)
+
+// TestHAR tests recording the network traffic (with a redirect), and
+// scrubbing the credentials (and the PIN and code entered) from it.
+func TestHAR(t *testing.T) {
+	var (
+		dir  string
+		buf  []byte
+		doc  har.HAR
+		r, _ = New(Options{OldPassword: "old-pw", NewPassword: "new-pw"})
+		h    = newHARRecorder(r.redact)
+		now  = time.Now()
+		at   = func(ms int) *cdp.MonotonicTime {
+			t := cdp.MonotonicTime(now.Add(time.Duration(ms) * time.Millisecond))
+			return &t
+		}
+		wall = cdp.TimeSinceEpoch(now)
+		err  error
+	)
+
+	r.secret("4321", nil)
+	r.secret("987654", nil)
+
+	for _, ev := range []interface{}{
+		&network.EventRequestWillBeSent{
+			RequestID: "1",
+			Timestamp: at(0),
+			WallTime:  &wall,
+			Request: &network.Request{
+				URL:    "https://fake.test/login?next=/password&token=abc",
+				Method: "POST",
+				Headers: network.Headers{
+					"Content-Type": "application/x-www-form-urlencoded",
+					"Cookie":       "NetflixId=secret",
+					"Accept":       "text/html",
+					"User-Agent":   "HeadlessChrome",
+				},
+				PostData:    "userLoginId=stub%40example.com&password=old-pw&flow=web",
+				HasPostData: true,
+			},
+		},
+		&network.EventRequestWillBeSent{
+			RequestID: "1",
+			Timestamp: at(10),
+			Request: &network.Request{
+				URL:    "https://fake.test/password?hint=new-pw",
+				Method: "GET",
+			},
+			RedirectResponse: &network.Response{
+				Status:     302,
+				StatusText: "Found",
+				Headers: network.Headers{
+					"location":   "/password?hint=new-pw",
+					"set-cookie": "NetflixId=secret",
+				},
+			},
+		},
+		&network.EventResponseReceived{
+			RequestID: "1",
+			Response:  &network.Response{Status: 200, MimeType: "text/html"},
+		},
+		&network.EventLoadingFinished{
+			RequestID: "1", Timestamp: at(25), EncodedDataLength: 42,
+		},
+		&network.EventRequestWillBeSent{
+			RequestID: "2",
+			Timestamp: at(30),
+			Request: &network.Request{
+				URL: "https://fake.test/x?entry=4321&field=987654", Method: "GET",
+			},
+		},
+		&network.EventLoadingFailed{
+			RequestID: "2", Timestamp: at(40), ErrorText: "net::ERR_FAILED",
+		},
+	} {
+		h.handle(ev)
+	}
+
+	if dir, err = ioutil.TempDir("", "har"); err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = h.write(filepath.Join(dir, "out.har")); err != nil {
+		t.Fatalf("error: unable to write the HAR file: %s", err)
+	}
+
+	if buf, err = ioutil.ReadFile(filepath.Join(dir, "out.har")); err != nil {
+		t.Fatalf("error: unable to read the HAR file: %s", err)
+	}
+
+	for _, secret := range []string{
+		"old-pw", "new-pw", "secret", "abc", "stub", "4321", "987654",
+	} {
+		if strings.Contains(string(buf), secret) {
+			t.Fatalf("the HAR file was not scrubbed of %q:\n%s", secret, buf)
+		}
+	}
+
+	if err = json.Unmarshal(buf, &doc); err != nil {
+		t.Fatalf("error: unable to parse the HAR file: %s", err)
+	}
+
+	if n := len(doc.Log.Entries); n != 3 {
+		t.Fatalf("expected 3 entries, got: %d", n)
+	}
+
+	var (
+		login = doc.Log.Entries[0]
+		page  = doc.Log.Entries[1]
+		fail  = doc.Log.Entries[2]
+	)
+
+	switch {
+	case login.Response.Status != 302 || login.Time != 10:
+		t.Fatalf("unexpected redirect: %+v", login.Response)
+	case login.Request.PostData == nil || len(login.Request.PostData.Params) != 3:
+		t.Fatalf("unexpected form fields: %+v", login.Request.PostData)
+	case login.Request.PostData.Params[0].Value != "web":
+		t.Fatalf("unexpected form field: %+v", login.Request.PostData.Params[0])
+	case !strings.Contains(string(buf), "HeadlessChrome"):
+		t.Fatalf("the User-Agent header was scrubbed:\n%s", buf)
+	case page.Response.Status != 200 || page.Response.BodySize != 42:
+		t.Fatalf("unexpected response: %+v", page.Response)
+	case fail.Comment != "net::ERR_FAILED":
+		t.Fatalf("unexpected failure: %+v", fail)
+	}
+}
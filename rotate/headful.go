@@ -0,0 +1,207 @@
+package rotate
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pauseKey is the context key for pausing the flow (see Options.Headful).
+type pauseKey struct{}
+
+// pauseFunc pauses the flow on a page it cannot handle, until the operator
+// resumes it, or the page moves on (i.e., until ready reports true).
+type pauseFunc func(ctx context.Context, e *pageError, ready func() bool) error
+
+// withPause returns a context, which pauses the flow with the given function.
+func withPause(ctx context.Context, fn pauseFunc) context.Context {
+	return context.WithValue(ctx, pauseKey{}, fn)
+}
+
+// pauser returns the function to pause the flow with (nil if the flow
+// should fail instead).
+func pauser(ctx context.Context) pauseFunc {
+	fn, _ := ctx.Value(pauseKey{}).(pauseFunc)
+	return fn
+}
+
+// clockKey is the context key for the clock of a pausable timeout.
+type clockKey struct{}
+
+// pauseClock is a timeout, which is stopped while the flow is paused.
+type pauseClock struct {
+	sync.Mutex
+	timer  *time.Timer
+	left   time.Duration // Time left, as of `since'.
+	since  time.Time
+	paused time.Duration // Time spent paused (so far).
+}
+
+// withPausableTimeout returns a context, which is cancelled after the given
+// duration (not counting the pauses), and the clock to pause it with.
+func withPausableTimeout(
+	ctx context.Context, timeout time.Duration,
+) (context.Context, context.CancelFunc, *pauseClock) {
+	var (
+		cancel context.CancelFunc
+		c      = &pauseClock{left: timeout, since: time.Now()}
+	)
+
+	ctx, cancel = context.WithCancel(ctx)
+	ctx = context.WithValue(ctx, clockKey{}, c)
+	c.timer = time.AfterFunc(timeout, cancel)
+
+	return ctx, func() {
+		c.timer.Stop()
+		cancel()
+	}, c
+}
+
+// pause stops the clock.
+func (c *pauseClock) pause() {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.timer.Stop() {
+		c.left -= time.Since(c.since)
+	} else {
+		c.left = 0
+	}
+	c.since = time.Now()
+}
+
+// resume restarts the clock, with the time that was left.
+func (c *pauseClock) resume() {
+	c.Lock()
+	defer c.Unlock()
+
+	c.paused += time.Since(c.since)
+	c.since = time.Now()
+	c.timer.Reset(c.left)
+}
+
+// pausedFor returns the time the clock of the context (if any) has spent
+// paused, so that the deadlines within it can be extended as well.
+func pausedFor(ctx context.Context) time.Duration {
+	var c, ok = ctx.Value(clockKey{}).(*pauseClock)
+
+	if !ok {
+		return 0
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return c.paused
+}
+
+// input returns the lines read from Options.Input (nil if there is none).
+// The lines are read in the background (from the first call on), so that
+// a prompt which is abandoned does not hold on to the input.
+func (r *Rotator) input() <-chan string {
+	if r.opts.Input == nil {
+		return nil
+	}
+
+	r.once.Do(func() {
+		var lines = make(chan string)
+
+		go func() {
+			var sc = bufio.NewScanner(r.opts.Input)
+
+			for sc.Scan() {
+				lines <- sc.Text()
+			}
+			close(lines)
+		}()
+
+		r.lines = lines
+	})
+
+	return r.lines
+}
+
+// prompt prompts for a line on Options.Input (which is echoed); blank lines
+// (e.g., left over from a pause) are skipped.
+func (r *Rotator) prompt(
+	ctx context.Context, format string, args ...interface{},
+) (string, error) {
+	var (
+		line  string
+		ok    bool
+		lines = r.input()
+	)
+
+	inpColor(os.Stderr, format, args...)
+
+	for {
+		select {
+		case line, ok = <-lines:
+			if !ok {
+				fmt.Fprintln(os.Stderr)
+				return "", fmt.Errorf("no more input")
+			}
+
+			if line = strings.TrimSpace(line); line != "" {
+				return line, nil
+			}
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return "", ctx.Err()
+		}
+	}
+}
+
+// pause waits for the operator to take care of a page in the browser window:
+// until they press Enter, or until the page moves on by itself. The clock is
+// stopped meanwhile, unless there is no input to resume the flow with.
+func (r *Rotator) pause(
+	ctx context.Context, c *pauseClock, e *pageError, ready func() bool,
+) error {
+	var (
+		ok    bool
+		lines = r.input()
+	)
+
+	if lines != nil {
+		c.pause()
+		defer func() {
+			if lines != nil {
+				c.resume()
+			}
+		}()
+	}
+
+	wrnColor(os.Stderr, "WRN: Paused on an unexpected page: %s.\n", e)
+	inpColor(
+		os.Stderr,
+		"Continue in the browser window, then press Enter (or wait): ",
+	)
+
+	for {
+		select {
+		case _, ok = <-lines:
+			if ok {
+				return nil
+			}
+
+			// Without any more input, only the page can resume the flow
+			// (before the timeout).
+			lines = nil
+			c.resume()
+		case <-ctx.Done():
+			fmt.Fprintln(os.Stderr)
+			return ctx.Err()
+		case <-time.After(netflixPollWait * time.Millisecond):
+			if ready() {
+				fmt.Fprintln(os.Stderr)
+				infColor(os.Stderr, "INF: The page moved on, resuming.\n")
+				return nil
+			}
+		}
+	}
+}
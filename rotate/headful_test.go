@@ -0,0 +1,125 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestHeadful tests pausing the flow on a page it cannot handle, until the
+// page moves on (or the operator presses Enter).
+func TestHeadful(t *testing.T) {
+	for _, solved := range []bool{true, false} {
+		var (
+			r   *Rotator
+			res *Result
+			f   = newFakeBrowser()
+			err error
+		)
+
+		r, err = New(Options{
+			Username:    "stub@example.com",
+			OldPassword: "old",
+			NewPassword: "new",
+			BaseURL:     "http://fake.test",
+			LoginPath:   "/login",
+			Browser:     f,
+			Headful:     true,
+			Input:       strings.NewReader("\n"),
+			Timeout:     time.Second,
+		})
+		if err != nil {
+			t.Fatalf("error: unable to create a rotator: %s", err)
+		}
+
+		(&fakeAccount{password: "old", gate: "challenge"}).script(f, r)
+		if solved {
+			f.pages["challenge"].solved = "password"
+		}
+
+		res, err = r.Rotate(context.Background())
+		switch {
+		case solved && (err != nil || !res.Updated):
+			t.Fatalf("the flow did not resume: %v (%+v)", err, res)
+		case !solved && !errors.Is(err, KindExecFail):
+			// Enter resumes the flow, which pauses on the same page again,
+			// until it times out.
+			t.Fatalf("unexpected error for an unsolved page: %v", err)
+		}
+	}
+}
+
+// TestHeadfulUnknown tests pausing the flow on an unknown page (e.g., a
+// consent banner) as soon as it settles, instead of leaving it, or waiting
+// until the end of the phase.
+func TestHeadfulUnknown(t *testing.T) {
+	for _, solved := range []bool{true, false} {
+		var (
+			r     *Rotator
+			res   *Result
+			f     = newFakeBrowser()
+			input = ""
+			err   error
+		)
+
+		// Without a solution, Enter resumes the flow, which then leaves
+		// the page (for the password page).
+		if !solved {
+			input = "\n"
+		}
+
+		r, err = New(Options{
+			Username:    "stub@example.com",
+			OldPassword: "old",
+			NewPassword: "new",
+			BaseURL:     "http://fake.test",
+			LoginPath:   "/login",
+			Browser:     f,
+			Headful:     true,
+			Input:       strings.NewReader(input),
+			Timeout:     5 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("error: unable to create a rotator: %s", err)
+		}
+
+		(&fakeAccount{password: "old", gate: "consent"}).script(f, r)
+		f.add("consent", &fakePage{})
+		if solved {
+			f.pages["consent"].solved = "password"
+		}
+
+		// The phase would only end (and leave the page) after the timeout.
+		if res, err = r.Rotate(context.Background()); err != nil || !res.Updated {
+			t.Fatalf("the flow did not pause (solved: %t): %v", solved, err)
+		}
+	}
+}
+
+// TestPauseClock tests stopping the timeout while the flow is paused.
+func TestPauseClock(t *testing.T) {
+	var ctx, cancel, c = withPausableTimeout(
+		context.Background(), 50*time.Millisecond,
+	)
+	defer cancel()
+
+	c.pause()
+	select {
+	case <-ctx.Done():
+		t.Fatalf("the context timed out while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	c.resume()
+	if p := pausedFor(ctx); p < 100*time.Millisecond {
+		t.Fatalf("unexpected time spent paused: %s", p)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("the context did not time out once resumed")
+	}
+}
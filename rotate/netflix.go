@@ -0,0 +1,212 @@
+package rotate
+
+import (
+	"context"
+	"os"
+
+	"github.com/chromedp/chromedp"
+)
+
+// SiteNetflix is the name of the Netflix driver (the default).
+const SiteNetflix = "netflix"
+
+// netflixDriver is the driver for Netflix.
+type netflixDriver struct {
+	routes  *netflixRoutes
+	sel     *Selectors
+	flow    *Flow
+	profile string // The profile to pick on the profile gate.
+}
+
+// newNetflixDriver creates the driver for Netflix; the routes (the selectors
+// and the flow) come from the options.
+func newNetflixDriver(opts Options) (SiteDriver, error) {
+	var (
+		d = &netflixDriver{
+			routes:  &netflixRoutes{},
+			sel:     opts.Selectors,
+			flow:    opts.Flow,
+			profile: opts.Profile,
+		}
+		err error
+	)
+
+	err = d.routes.loadRoutes(
+		opts.BaseURL, opts.LoginPath, opts.PasswordPath, opts.SignoutPath,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.sel == nil {
+		if d.sel, err = LoadSelectors(""); err != nil {
+			return nil, err
+		}
+	}
+
+	if d.flow == nil {
+		if d.flow, err = LoadFlow(""); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = d.flow.check(d.sel); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// vars returns the variables for the templates in the flow.
+func (d *netflixDriver) vars() *flowVars {
+	return &flowVars{
+		LoginURL:    d.routes.loginURL(),
+		PasswordURL: d.routes.passwordURL(),
+	}
+}
+
+// Name satisfies the SiteDriver interface.
+func (d *netflixDriver) Name() string {
+	return SiteNetflix
+}
+
+// BaseURL satisfies the SiteDriver interface.
+func (d *netflixDriver) BaseURL() string {
+	return d.routes.baseURL
+}
+
+// Login satisfies the SiteDriver interface.
+func (d *netflixDriver) Login(
+	ctx context.Context, phase, username, password string,
+) (*PhaseResult, error) {
+	var (
+		vars = d.vars()
+		t    = &loginTasks{profile: d.profile}
+		err  error
+	)
+
+	vars.Username, vars.OldPassword = username, password
+
+	if t.start, err = compile(d.flow.Start, d.sel, vars, nil); err != nil {
+		return &PhaseResult{Phase: phase, State: StateUnknown}, err
+	}
+
+	if t.login, err = compile(d.flow.Login, d.sel, vars, nil); err != nil {
+		return &PhaseResult{Phase: phase, State: StateUnknown}, err
+	}
+
+	t.challenge = func(code string) (chromedp.Tasks, error) {
+		vars.Code = code
+		return compile(d.flow.Challenge, d.sel, vars, nil)
+	}
+
+	t.pin = func(pin string) (chromedp.Tasks, error) {
+		vars.PIN = pin
+		return compile(d.flow.Profile, d.sel, vars, nil)
+	}
+
+	return runLogin(ctx, phase, d.routes, d.sel, t)
+}
+
+// ChangePassword satisfies the SiteDriver interface.
+func (d *netflixDriver) ChangePassword(
+	ctx context.Context, old, new string, devLogout bool,
+) (*PhaseResult, bool, error) {
+	var (
+		vars      = d.vars()
+		update    chromedp.Tasks
+		submitted bool
+		res       *PhaseResult
+		err       error
+	)
+
+	vars.OldPassword, vars.NewPassword = old, new
+	vars.DevLogout = devLogout
+
+	update, err = compile(d.flow.Update, d.sel, vars, &submitted)
+	if err != nil {
+		return &PhaseResult{Phase: PhaseUpdate, State: StateUnknown}, false, err
+	}
+
+	res, err = runUpdate(ctx, d.routes, d.sel, update)
+	return res, submitted, err
+}
+
+// SignOutDevices satisfies the DeviceSignOuter interface.
+func (d *netflixDriver) SignOutDevices(
+	ctx context.Context,
+) (*PhaseResult, error) {
+	var (
+		signout chromedp.Tasks
+		err     error
+	)
+
+	if signout, err = compile(d.flow.Signout, d.sel, d.vars(), nil); err != nil {
+		return &PhaseResult{Phase: PhaseSignout, State: StateUnknown}, err
+	}
+
+	return runSignout(ctx, d.routes, d.sel, signout)
+}
+
+// FailureReason satisfies the SiteDriver interface.
+func (d *netflixDriver) FailureReason(
+	ctx context.Context, phase string,
+) (*Error, bool) {
+	var sels []*selector
+
+	switch phase {
+	case PhaseLogin:
+		sels = errorSelectors(d.flow.Login, d.sel)
+	case PhaseUpdate:
+		sels = errorSelectors(d.flow.Update, d.sel)
+	}
+
+	return getFailureReason(ctx, phase, sels)
+}
+
+// CheckSelectors satisfies the SelectorChecker interface.
+func (d *netflixDriver) CheckSelectors(
+	ctx context.Context, username, password string,
+) ([]SelectorCheck, error) {
+	return doctor(ctx, d, username, password)
+}
+
+// getFailureReason gets (and classifies) the reason for failed actions,
+// from the first of the selectors on the page.
+func getFailureReason(
+	ctx context.Context, action string, sels []*selector,
+) (*Error, bool) {
+	var (
+		ok  bool
+		xp  string
+		sel *selector
+		e   *Error
+	)
+
+	if action != PhaseLogin && action != PhaseUpdate {
+		return classify(action, "", "Unknown error."), true
+	}
+
+	for _, sel = range sels {
+		xp, ok, _ = sel.match(ctx)
+		if !ok {
+			continue
+		}
+
+		e = classify(action, sel.name, extractText(ctx, xp))
+		if e.Kind == KindUnclassified {
+			wrnColor(
+				os.Stderr,
+				"WRN: The failure message was not recognized (%s).\n", e.Msg,
+			)
+		} else if Verbose {
+			dbgColor(
+				os.Stderr, "DBG: Failure: %s (by `%s').\n", e.Kind, sel.name,
+			)
+		}
+
+		return e, true
+	}
+
+	return nil, false
+}
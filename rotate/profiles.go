@@ -0,0 +1,266 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// pinKey is the context key for getting the PIN of a profile.
+type pinKey struct{}
+
+// pinFunc gets the PIN of a (locked) profile.
+type pinFunc func(ctx context.Context) (string, error)
+
+// withPIN returns a context, which gets the PIN of a profile with the given
+// function.
+func withPIN(ctx context.Context, fn pinFunc) context.Context {
+	return context.WithValue(ctx, pinKey{}, fn)
+}
+
+// pinSource returns the function to get the PIN of a profile with (nil if
+// there is none, and locked profiles cannot be picked).
+func pinSource(ctx context.Context) pinFunc {
+	fn, _ := ctx.Value(pinKey{}).(pinFunc)
+	return fn
+}
+
+// profileGate returns a handler for the profile gate, which picks a profile
+// (entering its PIN, if it is locked), and then loads the password page
+// (only once).
+func profileGate(
+	routes *netflixRoutes, sel *Selectors, t *loginTasks,
+) stateHandler {
+	return func(ctx context.Context, visits int) error {
+		var (
+			xp     string
+			pin    string
+			locked bool
+			tasks  chromedp.Tasks
+			gone   = []*selector{sel.Profiles.Gate}
+			err    error
+		)
+
+		if visits > 1 {
+			return newPageError(ctx, StateProfiles, stateReasons[StateProfiles])
+		}
+
+		if xp, locked, err = pickProfile(ctx, sel, t.profile); err != nil {
+			return err
+		}
+
+		// The PIN is asked for before the (locked) profile is picked.
+		if locked {
+			if pin, err = getPIN(ctx); err != nil {
+				return err
+			}
+
+			if tasks, err = t.pin(pin); err != nil {
+				return err
+			}
+			gone = append(gone, sel.Profiles.Pin)
+		}
+
+		if err = browser(ctx).Click(ctx, xp); err != nil {
+			return err
+		}
+
+		if err = exec(ctx, tasks); err != nil {
+			return err
+		}
+
+		// The gate leads to the home page (not to the password page).
+		err = waitGone(ctx, time.Now().Add(phaseWait(ctx)), gone)
+		if err != nil {
+			return err
+		}
+
+		return exec(ctx, chromedp.Tasks{navigate(routes.passwordURL())})
+	}
+}
+
+// pickProfile finds a profile on the gate: by name, or else the first one
+// without a lock. This returns the XPath of the profile, and if it is locked.
+func pickProfile(
+	ctx context.Context, sel *Selectors, name string,
+) (string, bool, error) {
+	var (
+		entry  string
+		xp     string
+		txt    string
+		ok     bool
+		locked bool
+		names  []string
+	)
+
+	if entry, ok, _ = sel.Profiles.Entry.match(ctx); !ok {
+		return "", false, newPageError(
+			ctx, StateProfiles, "no profiles were found on the profile gate",
+		)
+	}
+
+	for i := 1; ; i++ {
+		xp = fmt.Sprintf("(%s)[%d]", entry, i)
+		ok, _ = jsEval(ctx, fmt.Sprintf(netflixEval, xp, " !== null"))
+		if !ok {
+			break
+		}
+
+		txt = strings.TrimSpace(extractText(ctx, xp))
+		locked = isLocked(ctx, sel.Profiles.Lock, xp)
+		names = append(names, fmt.Sprintf("%q", txt))
+
+		switch {
+		case name != "" && strings.EqualFold(txt, name):
+		case name == "" && !locked:
+		default:
+			continue
+		}
+
+		if Verbose {
+			dbgColor(
+				os.Stderr, "DBG: Picking the profile: %s (locked: %t).\n",
+				txt, locked,
+			)
+		}
+
+		return xp, locked, nil
+	}
+
+	if name != "" {
+		return "", false, newPageError(
+			ctx, StateProfiles, fmt.Sprintf(
+				"no profile named \"%s\" (found: %s)",
+				name, strings.Join(names, ", "),
+			),
+		)
+	}
+
+	return "", false, newPageError(
+		ctx, StateProfiles, "every profile is locked (pick one by name)",
+	)
+}
+
+// isLocked reports if a profile (by its XPath) has a lock on it; the lock
+// is looked up within the profile, by any of its strategies.
+func isLocked(ctx context.Context, lock *selector, xp string) bool {
+	for _, st := range lock.chain {
+		expr := fmt.Sprintf(netflixEval, xp+st.xpath(), " !== null")
+		if ok, _ := jsEval(ctx, expr); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pin gets the PIN of a locked profile from Options.PIN, or else from
+// a prompt on Options.Input, only if it is a terminal (i.e., it has a file
+// descriptor, like *os.File, for one), with the echo turned off so that the
+// PIN is not shown. The prompt reads from the same lines
+// as the others, so that it does not compete with them for the input. The
+// clock is stopped meanwhile, for up to `netflixCodeWait' seconds.
+func (r *Rotator) pin(ctx context.Context, c *pauseClock) (string, error) {
+	var (
+		pin     string
+		f       interface{ Fd() uintptr }
+		ok      bool
+		restore func()
+		cancel  context.CancelFunc
+		err     error
+	)
+
+	c.pause()
+	defer c.resume()
+
+	if r.opts.PIN != nil {
+		return r.opts.PIN()
+	}
+
+	f, ok = r.opts.Input.(interface{ Fd() uintptr })
+	if !ok || !terminal.IsTerminal(int(f.Fd())) {
+		return "", fmt.Errorf(
+			"the input is not a terminal (set $NETFLIX_PROFILE_PIN)",
+		)
+	}
+
+	if restore, err = noEcho(int(f.Fd())); err != nil {
+		return "", fmt.Errorf("%s (set $NETFLIX_PROFILE_PIN)", err)
+	}
+	defer restore()
+
+	ctx, cancel = context.WithTimeout(ctx, netflixCodeWait*time.Second)
+	defer cancel()
+
+	// The Enter (which ends the PIN) is not echoed either.
+	pin, err = r.prompt(ctx, "Profile PIN (for %s): ", r.opts.Username)
+	if err == nil {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return pin, err
+}
+
+// getPIN gets the PIN of a locked profile.
+func getPIN(ctx context.Context) (string, error) {
+	var (
+		pin string
+		fn  = pinSource(ctx)
+		err error
+	)
+
+	if fn == nil {
+		return "", newPageError(
+			ctx, StateProfiles, "the profile is locked, and there is no PIN",
+		)
+	}
+
+	if pin, err = fn(ctx); err != nil {
+		return "", newPageError(
+			ctx, StateProfiles, "unable to get the PIN: "+err.Error(),
+		)
+	}
+
+	return pin, nil
+}
+
+// waitGone polls the page until none of the selectors are on it; this fails
+// (with a pageError) if any of them are still there at the deadline.
+func waitGone(
+	ctx context.Context, deadline time.Time, sels []*selector,
+) error {
+	var (
+		i   int
+		sel *selector
+	)
+
+	for {
+		for _, sel = range sels {
+			if i, _ = sel.find(ctx); i >= 0 {
+				break
+			}
+		}
+
+		if i < 0 {
+			return nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return newPageError(
+				ctx, StateProfiles,
+				fmt.Sprintf("still on the profile gate (`%s')", sel.name),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(netflixPollWait * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestProfiles tests picking a profile on the profile gate (by name, or the
+// first one without a lock), and entering its PIN.
+func TestProfiles(t *testing.T) {
+	var tests = []struct {
+		name    string
+		profile string
+		pin     bool
+		input   string // Input for the PIN prompt (if any).
+		kind    *Kind  // Expected kind of error (nil for none).
+		picked  string // The profile that was picked.
+	}{
+		{name: "first unlocked", picked: "Kids"},
+		{name: "by name", profile: "kids", picked: "Kids"},
+		{name: "locked", profile: "Owner", pin: true, picked: "Owner"},
+		{
+			name: "locked (not a terminal)", profile: "Owner",
+			input: "\n1234\n", kind: KindPageFail,
+		},
+		{name: "locked without a PIN", profile: "Owner", kind: KindPageFail},
+		{name: "unknown name", profile: "Guest", kind: KindPageFail},
+	}
+
+	for _, test := range tests {
+		var (
+			r      *Rotator
+			res    *Result
+			f      = newFakeBrowser()
+			picked string
+			asked  bool
+			err    error
+		)
+
+		opts := Options{
+			Username:    "stub@example.com",
+			OldPassword: "old",
+			NewPassword: "new",
+			BaseURL:     "http://fake.test",
+			LoginPath:   "/login",
+			Browser:     f,
+			Profile:     test.profile,
+		}
+		if test.input != "" {
+			opts.Input = strings.NewReader(test.input)
+		}
+		if test.pin {
+			opts.PIN = func() (string, error) {
+				asked = true
+				return "1234", nil
+			}
+		}
+
+		if r, err = New(opts); err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+		(&fakeAccount{password: "old", gate: "profiles"}).script(f, r)
+
+		var (
+			s     = r.site.(*netflixDriver).sel
+			entry = xp(s.Profiles.Entry)
+			owner = fmt.Sprintf("(%s)[1]", entry)
+			kids  = fmt.Sprintf("(%s)[2]", entry)
+			pick  = func(name, next string) func(*fakeBrowser) (string, error) {
+				return func(*fakeBrowser) (string, error) {
+					picked = name
+					return next, nil
+				}
+			}
+		)
+
+		f.add("profiles", &fakePage{
+			elems: map[string]string{
+				xp(s.Profiles.Gate):         "",
+				entry:                       "",
+				owner:                       "Owner",
+				owner + xp(s.Profiles.Lock): "",
+				kids:                        "Kids",
+			},
+			clicks: map[string]func(*fakeBrowser) (string, error){
+				owner: pick("Owner", "pin"),
+				kids:  pick("Kids", "browse"),
+			},
+		})
+
+		f.add("pin", &fakePage{
+			elems: map[string]string{xp(s.Profiles.Pin): ""},
+			inputs: map[string]func(*fakeBrowser) (string, error){
+				xp(s.Profiles.Pin): func(f *fakeBrowser) (string, error) {
+					if f.keys[xp(s.Profiles.Pin)] != "1234" {
+						return "", nil
+					}
+					return "browse", nil
+				},
+			},
+		})
+
+		f.add("browse", &fakePage{url: "http://fake.test/browse"})
+
+		res, err = r.Rotate(context.Background())
+		if KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, KindOf(err), err,
+			)
+		}
+
+		switch {
+		case test.kind == nil && !res.Updated:
+			t.Fatalf("%s: unexpected result: %+v", test.name, res)
+		case picked != test.picked:
+			t.Fatalf("%s: unexpected profile: %q", test.name, picked)
+		case asked != test.pin:
+			t.Fatalf("%s: unexpected PIN prompt: %v", test.name, asked)
+		}
+	}
+}
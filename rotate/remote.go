@@ -0,0 +1,138 @@
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// checkRemoteURL validates the URL of a remote browser (the DevTools
+// endpoint): either a websocket URL, or the HTTP address of the browser.
+func checkRemoteURL(raw string) error {
+	var (
+		u   *url.URL
+		err error
+	)
+
+	if u, err = url.Parse(raw); err != nil {
+		return err
+	}
+
+	switch u.Scheme {
+	case "ws", "wss", "http", "https":
+	default:
+		return fmt.Errorf(
+			"unsupported scheme: \"%s\" (expected ws, wss, http or https)",
+			u.Scheme,
+		)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("missing host: \"%s\"", raw)
+	}
+
+	return nil
+}
+
+// remoteURL resolves the websocket URL of a remote browser; for an HTTP
+// address, this is read from the `/json/version' endpoint.
+func remoteURL(ctx context.Context, raw string) (string, error) {
+	var (
+		req    *http.Request
+		resp   *http.Response
+		cancel context.CancelFunc
+		ver    struct {
+			URL string `json:"webSocketDebuggerUrl"`
+		}
+		err error
+	)
+
+	if strings.HasPrefix(raw, "ws://") || strings.HasPrefix(raw, "wss://") {
+		return raw, nil
+	}
+
+	ctx, cancel = context.WithTimeout(ctx, netflixRemoteWait*time.Second)
+	defer cancel()
+
+	req, err = http.NewRequest(
+		http.MethodGet, strings.TrimSuffix(raw, "/")+"/json/version", nil,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if resp, err = http.DefaultClient.Do(req.WithContext(ctx)); err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&ver); err != nil {
+		return "", fmt.Errorf("%s: %s", req.URL, err)
+	}
+
+	if ver.URL == "" {
+		return "", fmt.Errorf("%s: no websocket URL for the browser", req.URL)
+	}
+
+	return ver.URL, nil
+}
+
+// clearCookies deletes the cookies of the site (by the host of its URL, and
+// its subdomains) from a remote browser, which shares them across the tabs;
+// the cookies of the other sites are left alone. This is done in the
+// context of the tab (without its timeout, which may have run out).
+func (r *Rotator) clearCookies(ctx context.Context) error {
+	var (
+		u      *url.URL
+		cancel context.CancelFunc
+		err    error
+	)
+
+	if u, err = url.Parse(r.site.BaseURL()); err != nil {
+		return err
+	}
+	host := strings.TrimPrefix(u.Hostname(), "www.")
+
+	ctx, cancel = context.WithTimeout(
+		captureContext(ctx), netflixRemoteWait*time.Second,
+	)
+	defer cancel()
+
+	return run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var (
+			cookies []*network.Cookie
+			domain  string
+			err     error
+		)
+
+		if cookies, err = network.GetAllCookies().Do(ctx); err != nil {
+			return err
+		}
+
+		for _, c := range cookies {
+			domain = strings.TrimPrefix(c.Domain, ".")
+			if domain != host && !strings.HasSuffix(domain, "."+host) {
+				continue
+			}
+
+			err = network.DeleteCookies(c.Name).
+				WithDomain(c.Domain).WithPath(c.Path).Do(ctx)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
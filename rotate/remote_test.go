@@ -0,0 +1,48 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRemoteURL tests resolving the websocket URL of a remote browser.
+func TestRemoteURL(t *testing.T) {
+	var (
+		ws  = "ws://127.0.0.1:9222/devtools/browser/fake"
+		got string
+		err error
+		srv = httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/json/version" {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintf(w, `{"Browser": "Fake", "webSocketDebuggerUrl": %q}`, ws)
+			},
+		))
+	)
+	defer srv.Close()
+
+	for _, raw := range []string{ws, srv.URL, srv.URL + "/"} {
+		if got, err = remoteURL(context.Background(), raw); got != ws {
+			t.Fatalf("%s: unexpected URL: %q (%v)", raw, got, err)
+		}
+	}
+
+	if _, err = remoteURL(context.Background(), srv.URL+"/x"); err == nil ||
+		!strings.Contains(err.Error(), "404") {
+		t.Fatalf("unexpected error for a bad address: %v", err)
+	}
+
+	for _, raw := range []string{"ftp://127.0.0.1:9222", "ws://", "127.0.0.1:9222"} {
+		_, err = New(Options{RemoteURL: raw})
+		if !errors.Is(err, KindFlagFail) {
+			t.Fatalf("New(%q): unexpected error: %v", raw, err)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+// Package rotate rotates the password for a Netflix account, by driving a
+// (headless) browser through the login and the password pages.
+//
+//	r, err := rotate.New(rotate.Options{
+//		Username:    "user@example.com",
+//		OldPassword: "old",
+//		NewPassword: "new",
+//	})
+//	if err != nil {
+//		...
+//	}
+//
+//	res, err := r.Rotate(ctx)
+//	if errors.Is(err, rotate.KindIncorrectPass) {
+//		...
+//	}
+//
+// Errors are of the type *Error, and can be matched against the kinds
+// (e.g., KindLoginFail) with errors.Is; every kind maps to an exit status.
+package rotate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Steps of a rotation, reported to Options.Progress.
+const (
+	StepLoggedIn   = "logged-in"  // Logged in with the old password.
+	StepSubmitting = "submitting" // The update is about to be submitted.
+	StepUpdated    = "updated"    // The new password is live.
+)
+
+// DefaultTimeout is the default time to wait for a browser; each phase (the
+// login, and the update) may take up to half of the timeout.
+const DefaultTimeout = 2 * netflixPhaseWait * time.Second
+
+// Options are the options for a rotation.
+type Options struct {
+	Username    string // Username to login with.
+	OldPassword string // Current password.
+	NewPassword string // Updated password.
+	DevLogout   bool   // Force logout from all devices.
+	VerifyNew   bool   // Login with the new password, once updated.
+
+	Site string // Name of the site (see Sites; Netflix by default).
+
+	BaseURL      string     // Base URL for Netflix (optional).
+	LoginPath    string     // Path to the login page (optional).
+	PasswordPath string     // Path to the password page (optional).
+	SignoutPath  string     // Path to the sign-out page (optional).
+	Selectors    *Selectors // Selector profile (optional; see LoadSelectors).
+	Flow         *Flow      // Flow document (optional; see LoadFlow).
+
+	TmpDir   string        // Prefix for the temporary user-data directories.
+	DebugDir string        // Capture the page on failures, in here (optional).
+	HAR      string        // Record the network traffic, in here (optional).
+	ExecPath string        // Path to the `google-chrome' binary (optional).
+	Timeout  time.Duration // Time to wait for a browser (optional).
+
+	// RemoteURL (if set) is the DevTools endpoint of a running browser
+	// (ws://, or http://host:port) to attach to, instead of starting one.
+	RemoteURL string
+
+	// Headful shows the browser window, and pauses the flow on the pages
+	// it cannot handle (e.g., a CAPTCHA), until the operator presses Enter
+	// on Input, or the page moves on; the timeout is stopped meanwhile.
+	Headful bool
+	Input   io.Reader
+
+	// The verification code (when the site asks for one, after the login)
+	// is read from the output of CodeExec (a shell command), from CodeFile
+	// (polled until it shows up), or else from a prompt on Input.
+	CodeExec string
+	CodeFile string
+
+	// Profile is the profile to pick (by name) on the profile gate ("Who's
+	// watching?"); the first one without a lock is picked if empty. PIN (if
+	// set) is called for the PIN of a locked profile, or else it is prompted
+	// for on Input (without the echo), only if it is a terminal (i.e., it
+	// has an Fd method, like *os.File, for one).
+	Profile string
+	PIN     func() (string, error)
+
+	// Browser (if set) is driven instead of Chrome; it is shared by all
+	// the phases, including the ones that need a new browser.
+	Browser Browser
+
+	// Progress (if set) is called on every step of the rotation; an error
+	// from it stops the rotation, and is returned as is. The new password
+	// should be persisted on StepUpdated, before it is verified.
+	Progress func(step string) error
+}
+
+// Result is the result of a rotation.
+type Result struct {
+	Phases           []*PhaseResult // The phases of the browser flow.
+	Updated          bool           // The new password is live.
+	DevicesSignedOut bool           // All the devices were logged out.
+}
+
+// add records a phase, and prints how long it took.
+func (res *Result) add(r *PhaseResult) {
+	res.Phases = append(res.Phases, r)
+	r.report()
+}
+
+// Rotator rotates the password for an account.
+type Rotator struct {
+	opts Options
+	site SiteDriver
+	har  *harRecorder
+
+	once  sync.Once   // Starts reading the input.
+	lines chan string // The lines read from Options.Input.
+
+	mu      sync.Mutex
+	secrets []string // The PINs and codes entered (see redact).
+}
+
+// New creates a Rotator; the site driver (with its options, e.g.,
+// the routes) is set up and validated here.
+func New(opts Options) (*Rotator, error) {
+	var (
+		r   = &Rotator{opts: opts}
+		err error
+	)
+
+	if r.site, err = newSiteDriver(opts); err != nil {
+		return nil, NewError(
+			PhaseSetup, KindFlagFail, err, "Bad site configuration",
+		)
+	}
+
+	if r.opts.Timeout == 0 {
+		r.opts.Timeout = DefaultTimeout
+	}
+
+	if r.opts.RemoteURL != "" {
+		if err = checkRemoteURL(r.opts.RemoteURL); err != nil {
+			return nil, NewError(
+				PhaseSetup, KindFlagFail, err, "Bad remote debugging URL",
+			)
+		}
+	}
+
+	if r.opts.HAR != "" {
+		r.har = newHARRecorder(r.redact)
+	}
+
+	return r, nil
+}
+
+// Site returns the name of the site the rotator works against.
+func (r *Rotator) Site() string {
+	return r.site.Name()
+}
+
+// BaseURL returns the base URL the rotator works against.
+func (r *Rotator) BaseURL() string {
+	return r.site.BaseURL()
+}
+
+// newBrowser starts a new browser (with a new user-data directory), or opens
+// a new tab on the remote one (without the cookies of the site); this times
+// out after Options.Timeout, and the cancel function cleans up after it.
+func (r *Rotator) newBrowser(
+	ctx context.Context,
+) (context.Context, context.CancelFunc, error) {
+	var (
+		tmp     string
+		ws      string
+		execCtx context.Context
+		cancel  context.CancelFunc
+		cleanup context.CancelFunc
+		err     error
+
+		execCancel context.CancelFunc
+	)
+
+	if r.opts.Browser != nil {
+		cctx := withBrowser(ctx, r.opts.Browser)
+		ctx, cancel = r.timeout(cctx)
+		return withCapture(ctx, cctx), cancel, nil
+	}
+
+	if r.opts.RemoteURL != "" {
+		if ws, err = remoteURL(ctx, r.opts.RemoteURL); err != nil {
+			return nil, nil, NewError(
+				PhaseSetup, KindExecFail, err,
+				"Unable to reach the remote browser",
+			)
+		}
+
+		execCtx, execCancel = genRemoteContext(ctx, ws)
+		ctx, cancel = genBrowserContext(execCtx, execCancel, r.timeout)
+
+		// The tabs share the cookies, so the ones for the site are cleared
+		// (before and after), so that no session carries over between the
+		// runs, or the accounts.
+		cleanup = func() {
+			if e := r.clearCookies(ctx); e != nil {
+				wrnColor(os.Stderr, "WRN: Unable to clear the cookies: %s.\n", e)
+			}
+			cancel()
+		}
+
+		if err = r.clearCookies(ctx); err != nil {
+			cancel()
+			return nil, nil, NewError(
+				PhaseSetup, KindExecFail, err,
+				"Unable to clear the cookies on the remote browser",
+			)
+		}
+	} else {
+		if tmp, err = mkTmpDir(r.opts.TmpDir); err != nil {
+			return nil, nil, err
+		}
+
+		execCtx, execCancel = genExecContext(
+			ctx, tmp, r.opts.ExecPath, r.opts.Headful,
+		)
+		ctx, cancel = genBrowserContext(execCtx, execCancel, r.timeout)
+		cleanup = func() {
+			cancel()
+			os.RemoveAll(tmp)
+		}
+	}
+
+	if r.har != nil {
+		if err = r.har.listen(ctx); err != nil {
+			cleanup()
+			return nil, nil, NewError(
+				PhaseSetup, KindExecFail, err,
+				"Unable to record the network traffic",
+			)
+		}
+	}
+
+	return ctx, cleanup, nil
+}
+
+// timeout returns a context for the flow, which times out after
+// Options.Timeout; the timeout is stopped while waiting for the operator
+// (in a headful browser, for a verification code, or for a PIN). Each phase
+// (the login, and the update) may take up to half of it.
+func (r *Rotator) timeout(
+	ctx context.Context,
+) (context.Context, context.CancelFunc) {
+	var (
+		cancel context.CancelFunc
+		clock  *pauseClock
+	)
+
+	ctx = withPhaseWait(ctx, r.opts.Timeout/2)
+
+	if !r.opts.Headful && !r.hasCode() && r.opts.PIN == nil {
+		return context.WithTimeout(ctx, r.opts.Timeout)
+	}
+
+	ctx, cancel, clock = withPausableTimeout(ctx, r.opts.Timeout)
+
+	if r.opts.Headful {
+		ctx = withPause(ctx, func(
+			ctx context.Context, e *pageError, ready func() bool,
+		) error {
+			return r.pause(ctx, clock, e, ready)
+		})
+	}
+
+	if r.hasCode() {
+		ctx = withCode(ctx, func(ctx context.Context) (string, error) {
+			return r.secret(r.code(ctx, clock))
+		})
+	}
+
+	if r.opts.PIN != nil || r.opts.Input != nil {
+		ctx = withPIN(ctx, func(ctx context.Context) (string, error) {
+			return r.secret(r.pin(ctx, clock))
+		})
+	}
+
+	return ctx, cancel
+}
+
+// progress reports a step of the rotation.
+func (r *Rotator) progress(step string) error {
+	if r.opts.Progress == nil {
+		return nil
+	}
+
+	return r.opts.Progress(step)
+}
+
+// login starts the main browser of a flow (see newBrowser), and logs in
+// with the old password. The returned function is to be deferred (with the
+// error of the flow), even on errors: it captures the page on failures (but
+// for the phases with browsers of their own), closes the browser, and then
+// writes the network traffic (see Options.HAR).
+func (r *Rotator) login(
+	ctx context.Context, out *Result,
+) (context.Context, func(error), error) {
+	var (
+		phase   *PhaseResult
+		bwsrCtx context.Context
+		cancel  context.CancelFunc
+		err     error
+	)
+
+	finish := func(err error) {
+		var e *Error
+
+		if cancel != nil {
+			switch {
+			case err == nil:
+			case !errors.As(err, &e):
+				r.capture(bwsrCtx, PhasePersist)
+			case e.Phase != PhaseSetup &&
+				e.Phase != PhaseVerify && e.Phase != PhaseResolve:
+				r.capture(bwsrCtx, e.Phase)
+			}
+
+			cancel()
+		}
+
+		if r.har != nil {
+			if e := r.har.write(r.opts.HAR); e != nil {
+				wrnColor(os.Stderr, "WRN: Unable to write the HAR file: %s.\n", e)
+			}
+		}
+	}
+
+	if bwsrCtx, cancel, err = r.newBrowser(ctx); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, finish, e
+		}
+
+		return nil, finish, NewError(
+			PhaseSetup, KindTmpFail, err,
+			"Unable to create a temporary directory",
+		)
+	}
+
+	phase, err = r.site.Login(
+		bwsrCtx, PhaseLogin, r.opts.Username, r.opts.OldPassword,
+	)
+	out.add(phase)
+	if err != nil {
+		return bwsrCtx, finish, flowError(PhaseLogin, err)
+	}
+
+	// Check if the login works.
+	if phase.State == StateError {
+		if e, ok := r.site.FailureReason(bwsrCtx, PhaseLogin); ok {
+			return bwsrCtx, finish, e
+		}
+
+		return bwsrCtx, finish, NewError(
+			PhaseLogin, KindLoginFail, nil, "Login failed",
+		)
+	}
+
+	return bwsrCtx, finish, nil
+}
+
+// Rotate logs in with the old password, and updates it to the new one
+// (and verifies it, if asked to). The result is returned even on errors.
+// When the outcome of the update is unknown, the error is of the kind
+// KindStateOld, KindStateNew or KindStateFail, for the password that works.
+func (r *Rotator) Rotate(ctx context.Context) (out *Result, err error) {
+	var (
+		phase     *PhaseResult
+		submitted bool
+		state     error
+		hookErr   error
+		bwsrCtx   context.Context
+		finish    func(error)
+	)
+
+	out = &Result{Phases: []*PhaseResult{}}
+
+	// Start the browser, and login to the site.
+	bwsrCtx, finish, err = r.login(ctx, out)
+	defer func() { finish(err) }()
+	if err != nil {
+		return out, err
+	}
+
+	if err = r.progress(StepLoggedIn); err != nil {
+		return out, err
+	}
+
+	// Update the password; the step is reported right before the update is
+	// submitted (and not if it fails before that).
+	updCtx := withSubmit(bwsrCtx, func() error {
+		hookErr = r.progress(StepSubmitting)
+		return hookErr
+	})
+
+	phase, submitted, err = r.site.ChangePassword(
+		updCtx, r.opts.OldPassword, r.opts.NewPassword, r.opts.DevLogout,
+	)
+	out.add(phase)
+	if hookErr != nil {
+		return out, hookErr
+	}
+
+	if err != nil {
+		err = flowError(PhaseUpdate, err)
+		if !ambiguous(submitted, err) {
+			return out, err
+		}
+		wrnColor(os.Stderr, "WRN: %s\n", err)
+
+		// Find out which password works, so that access is not lost.
+		state = r.resolveState(ctx, out)
+		if !errors.Is(state, KindStateNew) {
+			return out, state
+		}
+	}
+
+	// Check if the update worked.
+	if phase.State == StateError {
+		if e, ok := r.site.FailureReason(bwsrCtx, PhaseUpdate); ok {
+			return out, e
+		}
+
+		return out, NewError(
+			PhaseUpdate, KindUpdateFail, nil, "Password update failed",
+		)
+	}
+
+	out.Updated = true
+	out.DevicesSignedOut = r.opts.DevLogout
+
+	if err = r.progress(StepUpdated); err != nil {
+		return out, err
+	}
+
+	// The new password works, but the update was not confirmed.
+	if state != nil {
+		return out, state
+	}
+
+	// Login with the new password (in a new browser).
+	if r.opts.VerifyNew {
+		if err = r.verifyNewPassword(ctx, out); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+// Check logs in with a password (in a new browser), to find out if it
+// works; the error is nil only if the login reaches the password page.
+func (r *Rotator) Check(ctx context.Context, password string) (*PhaseResult, error) {
+	var (
+		res *PhaseResult
+		err error
+	)
+
+	res, err = r.freshLogin(ctx, PhaseResolve, password)
+	res.report()
+
+	switch {
+	case err != nil:
+		return res, flowError(PhaseResolve, err)
+	case res.State != StatePassword:
+		return res, NewError(
+			PhaseResolve, KindLoginFail, nil,
+			"Unable to login: "+Pick(res.Reason, res.State),
+		)
+	}
+
+	return res, nil
+}
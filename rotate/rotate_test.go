@@ -0,0 +1,504 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/internal/nflxmock"
+)
+
+// TestNew tests validating the options.
+func TestNew(t *testing.T) {
+	var (
+		r   *Rotator
+		err error
+	)
+
+	for _, base := range []string{"ftp://example.com", "example.com", "http://"} {
+		_, err = New(Options{BaseURL: base})
+		if !errors.Is(err, KindFlagFail) {
+			t.Fatalf("New(%q): unexpected error: %v", base, err)
+		}
+	}
+
+	if r, err = New(Options{BaseURL: "http://127.0.0.1:8080/"}); err != nil {
+		t.Fatalf("error: unable to create a rotator: %s", err)
+	}
+
+	if r.BaseURL() != "http://127.0.0.1:8080" || r.opts.Timeout != DefaultTimeout {
+		t.Fatalf("unexpected defaults: %s, %s", r.BaseURL(), r.opts.Timeout)
+	}
+
+	d := r.site.(*netflixDriver)
+	if d.sel.Login.Username == nil || d.sel.Login.Username.String() == "" {
+		t.Fatalf("the built-in selectors were not loaded")
+	}
+
+	if _, err = New(Options{Site: "stub"}); !errors.Is(err, KindFlagFail) {
+		t.Fatalf("New: unexpected error for an unknown site: %v", err)
+	}
+}
+
+// fakeAccount scripts the Netflix pages on a fake browser, for an account.
+type fakeAccount struct {
+	password string // The current password.
+	reused   string // A previous password.
+	crash    bool   // Fail the click on the update (after it went through).
+	drop     bool   // Drop the session on the update (before it went through).
+	lock     bool   // Lock the account on the update (no password works).
+	stale    bool   // Confirm the update, without changing the password.
+	broken   bool   // Leave the submit button out of the password form.
+	gate     string // The page after the login (instead of the password page).
+	newGate  string // The gate once the password is updated.
+	code     string // The verification code (for the challenge gate).
+}
+
+// xp returns the XPath of the preferred strategy of a selector.
+func xp(sel *selector) string {
+	return sel.chain[0].xpath()
+}
+
+// script adds the pages to a fake browser.
+func (a *fakeAccount) script(f *fakeBrowser, r *Rotator) {
+	var (
+		d      = r.site.(*netflixDriver)
+		s      = d.sel
+		login  = d.routes.loginURL()
+		passwd = d.routes.passwordURL()
+		form   = func(sels ...*selector) map[string]string {
+			var elems = make(map[string]string)
+
+			for _, sel := range sels {
+				elems[xp(sel)] = ""
+			}
+
+			return elems
+		}
+	)
+
+	f.add("login", &fakePage{
+		url: login,
+		elems: form(
+			s.Login.Username, s.Login.Password, s.Login.Remember, s.Login.Submit,
+		),
+		clicks: map[string]func(*fakeBrowser) (string, error){
+			xp(s.Login.Submit): func(f *fakeBrowser) (string, error) {
+				switch {
+				case f.keys[xp(s.Login.Password)] != a.password:
+					return "login-error", nil
+				case a.gate != "":
+					return a.gate, nil
+				}
+
+				return "password", nil
+			},
+		},
+	})
+
+	f.add("login-error", &fakePage{
+		elems: map[string]string{
+			xp(s.Login.Username): "",
+			xp(s.Login.FailErr):  nflxmock.ErrIncorrectPass,
+		},
+	})
+
+	f.add("password", &fakePage{
+		url: passwd,
+		elems: form(
+			s.Update.OldPassword, s.Update.NewPassword, s.Update.CnfPassword,
+			s.Update.Logout, s.Update.Submit,
+		),
+		clicks: map[string]func(*fakeBrowser) (string, error){
+			xp(s.Update.Submit): func(f *fakeBrowser) (string, error) {
+				var pw = f.keys[xp(s.Update.NewPassword)]
+
+				switch {
+				case f.keys[xp(s.Update.OldPassword)] != a.password:
+					return "password-error", nil
+				case pw == a.reused:
+					return "reused-error", nil
+				}
+
+				switch {
+				case a.drop:
+					return "login", nil
+				case a.lock:
+					a.password = ""
+					return "login", nil
+				}
+
+				if a.stale {
+					return "success", nil
+				}
+
+				a.reused, a.password = a.password, pw
+				if a.newGate != "" {
+					a.gate = a.newGate
+				}
+				if a.crash {
+					return "", fmt.Errorf("fake: the browser crashed")
+				}
+
+				return "success", nil
+			},
+		},
+	})
+
+	f.add("password-error", &fakePage{
+		elems: map[string]string{
+			xp(s.Update.OldPassword):    "",
+			xp(s.Update.OldPasswordErr): nflxmock.ErrCurrentPass,
+		},
+	})
+
+	f.add("reused-error", &fakePage{
+		elems: map[string]string{
+			xp(s.Update.OldPassword):    "",
+			xp(s.Update.NewPasswordErr): nflxmock.ErrReusedPass,
+		},
+	})
+
+	if a.broken {
+		delete(f.pages["password"].elems, xp(s.Update.Submit))
+	}
+
+	f.add("success", &fakePage{
+		elems: map[string]string{xp(s.Update.Eval): nflxmock.MsgUpdated},
+	})
+
+	f.add("challenge", &fakePage{
+		elems: form(s.Challenge.Code, s.Challenge.Submit),
+		clicks: map[string]func(*fakeBrowser) (string, error){
+			xp(s.Challenge.Submit): func(f *fakeBrowser) (string, error) {
+				if f.keys[xp(s.Challenge.Code)] != a.code {
+					return "challenge", nil
+				}
+
+				return "password", nil
+			},
+		},
+	})
+}
+
+// TestRotate tests the browser flow (and the classification of the
+// failures) against the scripted pages.
+func TestRotate(t *testing.T) {
+	var tests = []struct {
+		name    string
+		account fakeAccount
+		opts    Options
+		kind    *Kind  // Expected kind of error (nil for none).
+		fail    string // The step to fail on (if any).
+		steps   string // Expected steps.
+		updated bool
+	}{
+		{
+			name:    "success",
+			account: fakeAccount{password: "old"},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			steps:   "logged-in,submitting,updated",
+			updated: true,
+		},
+		{
+			name:    "verified",
+			account: fakeAccount{password: "old"},
+			opts: Options{
+				OldPassword: "old", NewPassword: "new", VerifyNew: true,
+			},
+			steps:   "logged-in,submitting,updated",
+			updated: true,
+		},
+		{
+			name:    "new password rejected",
+			account: fakeAccount{password: "old", stale: true},
+			opts: Options{
+				OldPassword: "old", NewPassword: "new", VerifyNew: true,
+			},
+			kind:    KindNewPwFail,
+			steps:   "logged-in,submitting,updated",
+			updated: true,
+		},
+		{
+			name:    "new device check",
+			account: fakeAccount{password: "old", newGate: "challenge"},
+			opts: Options{
+				OldPassword: "old", NewPassword: "new", VerifyNew: true,
+			},
+			kind:    KindPageFail,
+			steps:   "logged-in,submitting,updated",
+			updated: true,
+		},
+		{
+			name:    "incorrect password",
+			account: fakeAccount{password: "old"},
+			opts:    Options{OldPassword: "bad", NewPassword: "new"},
+			kind:    KindIncorrectPass,
+		},
+		{
+			name:    "reused password",
+			account: fakeAccount{password: "old", reused: "new"},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindReusedPass,
+			steps:   "logged-in,submitting",
+		},
+		{
+			name:    "incomplete form",
+			account: fakeAccount{password: "old", broken: true},
+			opts: Options{
+				OldPassword: "old", NewPassword: "new",
+				Timeout: 500 * time.Millisecond,
+			},
+			kind:  KindExecFail,
+			steps: "logged-in",
+		},
+		{
+			name:    "progress fails before submitting",
+			account: fakeAccount{password: "old"},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindWriteFail,
+			fail:    StepSubmitting,
+			steps:   "logged-in,submitting",
+		},
+		{
+			name:    "verification code",
+			account: fakeAccount{password: "old", gate: "challenge"},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindPageFail,
+		},
+		{
+			name:    "crash after submitting",
+			account: fakeAccount{password: "old", crash: true},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindStateNew,
+			steps:   "logged-in,submitting,updated",
+			updated: true,
+		},
+		{
+			name:    "session dropped after submitting",
+			account: fakeAccount{password: "old", drop: true},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindStateOld,
+			steps:   "logged-in,submitting",
+		},
+		{
+			name:    "locked out after submitting",
+			account: fakeAccount{password: "old", lock: true},
+			opts:    Options{OldPassword: "old", NewPassword: "new"},
+			kind:    KindStateFail,
+			steps:   "logged-in,submitting",
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			r     *Rotator
+			res   *Result
+			f     = newFakeBrowser()
+			steps []string
+			err   error
+		)
+
+		test.opts.Username = "stub@example.com"
+		test.opts.BaseURL = "http://fake.test"
+		test.opts.LoginPath = "/login"
+		test.opts.Browser = f
+		test.opts.Progress = func(step string) error {
+			steps = append(steps, step)
+			if step == test.fail {
+				return NewError(PhaseUpdate, KindWriteFail, nil, "Stub")
+			}
+			return nil
+		}
+
+		if r, err = New(test.opts); err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+		test.account.script(f, r)
+
+		res, err = r.Rotate(context.Background())
+		if KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, KindOf(err), err,
+			)
+		}
+
+		if strings.Join(steps, ",") != test.steps {
+			t.Fatalf("%s: unexpected steps: %v", test.name, steps)
+		}
+
+		if res.Updated != test.updated {
+			t.Fatalf("%s: unexpected result: %+v", test.name, res)
+		}
+
+		// The update is not submitted after a failure on the step.
+		if test.fail == StepSubmitting && test.account.password != "old" {
+			t.Fatalf("%s: the update was submitted", test.name)
+		}
+	}
+}
+
+// TestPhaseWait tests that a phase takes up to half of the timeout: the
+// login gives up on an unknown page (and goes to the password page) before
+// the flow times out.
+func TestPhaseWait(t *testing.T) {
+	var (
+		r     *Rotator
+		res   *Result
+		f     = newFakeBrowser()
+		start = time.Now()
+		err   error
+	)
+
+	r, err = New(Options{
+		Username:    "stub@example.com",
+		OldPassword: "old",
+		NewPassword: "new",
+		BaseURL:     "http://fake.test",
+		LoginPath:   "/login",
+		Browser:     f,
+		Timeout:     2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("error: unable to create a rotator: %s", err)
+	}
+
+	(&fakeAccount{password: "old", gate: "consent"}).script(f, r)
+	f.add("consent", &fakePage{})
+
+	switch res, err = r.Rotate(context.Background()); {
+	case err != nil || !res.Updated:
+		t.Fatalf("the flow did not move on from the page: %v", err)
+	case time.Since(start) >= 2*time.Second:
+		t.Fatalf("the phase took the whole timeout: %s", time.Since(start))
+	}
+}
+
+// TestCheck tests finding out if a password works (see Rotator.Check).
+func TestCheck(t *testing.T) {
+	var tests = []struct {
+		name     string
+		account  fakeAccount
+		password string
+		kind     *Kind  // Expected kind of error (nil for none).
+		state    string // Expected state of the login.
+	}{
+		{
+			name:     "works",
+			account:  fakeAccount{password: "new"},
+			password: "new",
+			state:    StatePassword,
+		},
+		{
+			name:     "does not work",
+			account:  fakeAccount{password: "old"},
+			password: "new",
+			kind:     KindLoginFail,
+			state:    StateError,
+		},
+		{
+			name:     "verification code",
+			account:  fakeAccount{password: "new", gate: "challenge"},
+			password: "new",
+			kind:     KindPageFail,
+			state:    StateChallenge,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			r   *Rotator
+			res *PhaseResult
+			f   = newFakeBrowser()
+			err error
+		)
+
+		r, err = New(Options{
+			Username:  "stub@example.com",
+			BaseURL:   "http://fake.test",
+			LoginPath: "/login",
+			Browser:   f,
+		})
+		if err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+		test.account.script(f, r)
+
+		res, err = r.Check(context.Background(), test.password)
+		if KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, KindOf(err), err,
+			)
+		}
+
+		if res.Phase != PhaseResolve || res.State != test.state {
+			t.Fatalf("%s: unexpected result: %+v", test.name, res)
+		}
+	}
+}
+
+// stubDriver is a site driver, which accepts any password.
+type stubDriver struct {
+	logins int
+}
+
+func (d *stubDriver) Name() string    { return "stub" }
+func (d *stubDriver) BaseURL() string { return "http://stub.test" }
+
+func (d *stubDriver) Login(
+	ctx context.Context, phase, username, password string,
+) (*PhaseResult, error) {
+	d.logins++
+	return &PhaseResult{Phase: phase, State: StatePassword}, nil
+}
+
+func (d *stubDriver) ChangePassword(
+	ctx context.Context, old, new string, devLogout bool,
+) (*PhaseResult, bool, error) {
+	if err := Submitting(ctx); err != nil {
+		return &PhaseResult{Phase: PhaseUpdate, State: StateUnknown}, false, err
+	}
+
+	return &PhaseResult{Phase: PhaseUpdate, State: StateSuccess}, true, nil
+}
+
+func (d *stubDriver) FailureReason(
+	ctx context.Context, phase string,
+) (*Error, bool) {
+	return nil, false
+}
+
+// TestRegister tests rotating with a registered site driver.
+func TestRegister(t *testing.T) {
+	var (
+		r   *Rotator
+		res *Result
+		d   = &stubDriver{}
+		err error
+	)
+
+	Register("stub", func(Options) (SiteDriver, error) { return d, nil })
+	defer delete(sites, "stub")
+
+	r, err = New(Options{Site: "stub", VerifyNew: true, Browser: newFakeBrowser()})
+	if err != nil {
+		t.Fatalf("error: unable to create a rotator: %s", err)
+	}
+
+	if res, err = r.Rotate(context.Background()); err != nil || !res.Updated {
+		t.Fatalf("error: unable to rotate: %v (%+v)", err, res)
+	}
+
+	if d.logins != 2 || r.Site() != "stub" {
+		t.Fatalf("unexpected logins (with verification): %d", d.logins)
+	}
+
+	if _, err = r.Doctor(context.Background()); !errors.Is(err, KindFlagFail) {
+		t.Fatalf("Doctor: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package rotate
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// netflixRoutes is a wrapper for the URLs used in the browser flow.
+type netflixRoutes struct {
+	baseURL      string
+	loginPath    string
+	passwordPath string
+	signoutPath  string
+}
+
+// loadRoutes constructs the routes, falling back to the defaults.
+func (r *netflixRoutes) loadRoutes(base, login, passwd, signout string) error {
+	var (
+		u   *url.URL
+		err error
+	)
+
+	r.baseURL = strings.TrimSuffix(
+		Pick(base, netflixBaseURL), "/",
+	)
+	r.loginPath = Pick(login, netflixLoginPath)
+	r.passwordPath = Pick(passwd, netflixPasswordPath)
+	r.signoutPath = Pick(signout, netflixSignoutPath)
+
+	if u, err = url.Parse(r.baseURL); err != nil {
+		return err
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base URL: \"%s\"", r.baseURL)
+	}
+
+	if r.loginPath != "" && !strings.HasPrefix(r.loginPath, "/") {
+		return fmt.Errorf("invalid login path: \"%s\"", r.loginPath)
+	}
+
+	if !strings.HasPrefix(r.passwordPath, "/") {
+		return fmt.Errorf("invalid password path: \"%s\"", r.passwordPath)
+	}
+
+	if !strings.HasPrefix(r.signoutPath, "/") {
+		return fmt.Errorf("invalid sign-out path: \"%s\"", r.signoutPath)
+	}
+
+	return nil
+}
+
+// passwordURL returns the URL for the password page.
+func (r *netflixRoutes) passwordURL() string {
+	return r.baseURL + r.passwordPath
+}
+
+// signoutURL returns the URL for the page to sign out of all devices.
+func (r *netflixRoutes) signoutURL() string {
+	return r.baseURL + r.signoutPath
+}
+
+// loginURL returns the URL to start the login with. Without a login path,
+// this is the password page (which redirects to the login page).
+func (r *netflixRoutes) loginURL() string {
+	if r.loginPath == "" {
+		return r.passwordURL()
+	}
+
+	return fmt.Sprintf(
+		"%s%s?nextpage=%s",
+		r.baseURL, r.loginPath, url.QueryEscape(r.passwordURL()),
+	)
+}
@@ -0,0 +1,721 @@
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"gopkg.in/yaml.v2"
+)
+
+// mountVar is replaced with the base XPath (`mount') in the selectors.
+const mountVar = "{mount}"
+
+// Strategies for locating an element, in the order they are tried.
+const (
+	byID    = "id"    // The `id' attribute.
+	byName  = "name"  // The `name' attribute.
+	byAria  = "aria"  // The `aria-label' attribute.
+	byText  = "text"  // The visible text of a button (or a label).
+	byXpath = "xpath" // A positional XPath.
+)
+
+// strategyOrder is the precedence of the strategies.
+var strategyOrder = map[string]int{
+	byID:    0,
+	byName:  1,
+	byAria:  2,
+	byText:  3,
+	byXpath: 4,
+}
+
+// strategy is a single way of locating an element.
+type strategy struct {
+	kind  string
+	value string
+}
+
+// selector is a logical element on the page, located through a chain
+// of strategies; the first strategy that matches wins. In a selector
+// profile, this is either an XPath, or a list of single-key objects:
+//
+//	[{"id": "id_userLoginId"}, {"name": "userLoginId"}, {"xpath": "..."}]
+type selector struct {
+	name  string // Name of the element (e.g., `login.username').
+	chain []strategy
+}
+
+// Selectors is a wrapper for the selectors used in the browser flow.
+// These can be overridden with a selector profile (JSON or YAML).
+type Selectors struct {
+	Mount     string             `json:"mount" yaml:"mount"`
+	Login     loginSelectors     `json:"login" yaml:"login"`
+	Update    updateSelectors    `json:"update" yaml:"update"`
+	Profiles  profileSelectors   `json:"profiles" yaml:"profiles"`
+	Challenge challengeSelectors `json:"challenge" yaml:"challenge"`
+	Signout   signoutSelectors   `json:"signout" yaml:"signout"`
+}
+
+// loginSelectors has the selectors for the login page.
+type loginSelectors struct {
+	Username *selector `json:"username" yaml:"username"`
+	Password *selector `json:"password" yaml:"password"`
+	Remember *selector `json:"remember" yaml:"remember"`
+	Submit   *selector `json:"submit" yaml:"submit"`
+	Eval     *selector `json:"eval" yaml:"eval"`
+
+	// For getting login failure reasons.
+	UsernameErr *selector `json:"username_err" yaml:"username_err"`
+	PasswordErr *selector `json:"password_err" yaml:"password_err"`
+	FailErr     *selector `json:"fail_err" yaml:"fail_err"`
+}
+
+// updateSelectors has the selectors for the password page.
+type updateSelectors struct {
+	OldPassword *selector `json:"old_password" yaml:"old_password"`
+	NewPassword *selector `json:"new_password" yaml:"new_password"`
+	CnfPassword *selector `json:"cnf_password" yaml:"cnf_password"`
+	Logout      *selector `json:"logout" yaml:"logout"`
+	Submit      *selector `json:"submit" yaml:"submit"`
+	Eval        *selector `json:"eval" yaml:"eval"`
+
+	// For getting update failure reasons.
+	OldPasswordErr *selector `json:"old_password_err" yaml:"old_password_err"`
+	NewPasswordErr *selector `json:"new_password_err" yaml:"new_password_err"`
+	CnfPasswordErr *selector `json:"cnf_password_err" yaml:"cnf_password_err"`
+}
+
+// profileSelectors has the selectors for the profile gate ("Who's watching?").
+type profileSelectors struct {
+	Gate  *selector `json:"gate" yaml:"gate"`
+	Entry *selector `json:"entry" yaml:"entry"` // Every profile (by name).
+	Lock  *selector `json:"lock" yaml:"lock"`   // Looked up within a profile.
+	Pin   *selector `json:"pin" yaml:"pin"`
+}
+
+// challengeSelectors has the selectors for the verification code challenge.
+type challengeSelectors struct {
+	Code   *selector `json:"code" yaml:"code"`
+	Submit *selector `json:"submit" yaml:"submit"`
+}
+
+// signoutSelectors has the selectors for the page to sign out of all devices.
+type signoutSelectors struct {
+	Submit *selector `json:"submit" yaml:"submit"`
+	Eval   *selector `json:"eval" yaml:"eval"` // The confirmation.
+}
+
+// chain constructs a selector from pairs of strategies and values.
+func chain(pairs ...string) *selector {
+	var s = &selector{}
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		s.chain = append(s.chain, strategy{kind: pairs[i], value: pairs[i+1]})
+	}
+
+	return s
+}
+
+// defaultSelectors returns the built-in selectors (not yet expanded).
+func defaultSelectors() *Selectors {
+	return &Selectors{
+		Mount: netflixMnt,
+		Login: loginSelectors{
+			Username: chain(byID, "id_userLoginId", byName, "userLoginId"),
+			Password: chain(byID, "id_password", byName, "password"),
+			Remember: chain(
+				byText, "Remember me",
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/form/div[3]/div/label`,
+			),
+			Submit: chain(
+				byText, "Sign In",
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/form/button`,
+			),
+			Eval: chain(
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/div/div[2]`,
+			),
+
+			UsernameErr: chain(
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/form/div[1]/div[2]`,
+			),
+			PasswordErr: chain(
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/form/div[2]/div[2]`,
+			),
+			FailErr: chain(
+				byXpath, mountVar+`/div/div[3]/div/div/div[1]/div/div[2]`,
+			),
+		},
+		Update: updateSelectors{
+			OldPassword: chain(byID, "password", byName, "currentPassword"),
+			NewPassword: chain(byID, "pw_new", byName, "newPassword"),
+			CnfPassword: chain(byID, "pw_confirm", byName, "confirmNewPassword"),
+			Logout: chain(
+				byID, "bxid_signout_devices_signout_devices",
+				byName, "signOutDevices",
+			),
+			Submit: chain(
+				byText, "Save",
+				byXpath, mountVar+`/div/div/div[2]/div/div/div/button[1]`,
+			),
+			Eval: chain(
+				byXpath, mountVar+`/div/div/div[2]/div/div/div[1]/div/div[2]`,
+			),
+
+			OldPasswordErr: chain(byXpath, `//*[@id="lbl-password"]/div`),
+			NewPasswordErr: chain(byXpath, `//*[@id="lbl-pw_new"]/div`),
+			CnfPasswordErr: chain(byXpath, `//*[@id="lbl-pw_confirm"]/div`),
+		},
+		Profiles: profileSelectors{
+			Gate: chain(
+				byXpath, `//*[contains(@class, "list-profiles")]`,
+			),
+			Entry: chain(
+				byXpath,
+				`//*[contains(@class, "list-profiles")]//li[contains(@class, "profile")]`,
+			),
+			Lock: chain(
+				byXpath, `//*[contains(@class, "profile-lock")]`,
+			),
+			Pin: chain(
+				byXpath, `//input[contains(@class, "pin-number-input")]`,
+			),
+		},
+		Challenge: challengeSelectors{
+			Code: chain(
+				byName, "challengeCode",
+				byXpath, `//input[@autocomplete="one-time-code"]`,
+			),
+			Submit: chain(
+				byText, "Submit",
+				byXpath, `//form[.//input[@name="challengeCode"]]//button`,
+			),
+		},
+		Signout: signoutSelectors{
+			Submit: chain(
+				byText, "Sign Out",
+				byXpath, `//*[@data-uia="signout-all-devices-button"]`,
+			),
+			Eval: chain(
+				byXpath, `//*[@data-uia="signout-all-devices-confirmation"]`,
+			),
+		},
+	}
+}
+
+// LoadSelectors reads a selector profile on top of the built-in selectors.
+// Any selector missing from the profile retains the built-in value.
+func LoadSelectors(path string) (*Selectors, error) {
+	var (
+		buf []byte
+		dec *json.Decoder
+		sel = defaultSelectors()
+		err error
+	)
+
+	if path != "" {
+		if buf, err = ioutil.ReadFile(path); err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			err = yaml.UnmarshalStrict(buf, sel)
+		default:
+			dec = json.NewDecoder(bytes.NewReader(buf))
+			dec.DisallowUnknownFields()
+			err = dec.Decode(sel)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	if err = sel.expand(); err != nil {
+		if path != "" {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return nil, err
+	}
+
+	return sel, nil
+}
+
+// expand validates the selectors and substitutes the base XPath.
+func (s *Selectors) expand() error {
+	var err error
+
+	if err = checkXpath("mount", s.Mount); err != nil {
+		return err
+	}
+
+	if strings.Contains(s.Mount, mountVar) {
+		return fmt.Errorf("selector `mount' cannot refer to itself")
+	}
+
+	if err = expandFields("login", &s.Login, s.Mount); err != nil {
+		return err
+	}
+
+	if err = expandFields("update", &s.Update, s.Mount); err != nil {
+		return err
+	}
+
+	if err = expandFields("profiles", &s.Profiles, s.Mount); err != nil {
+		return err
+	}
+
+	if err = expandFields("challenge", &s.Challenge, s.Mount); err != nil {
+		return err
+	}
+
+	return expandFields("signout", &s.Signout, s.Mount)
+}
+
+// lookup finds a selector by name (e.g., `login.username').
+func (s *Selectors) lookup(name string) (*selector, bool) {
+	var sel *selector
+
+	for _, v := range []interface{}{
+		&s.Login, &s.Update, &s.Profiles, &s.Challenge, &s.Signout,
+	} {
+		val := reflect.ValueOf(v).Elem()
+		for i := 0; i < val.NumField(); i++ {
+			if sel = val.Field(i).Interface().(*selector); sel.name == name {
+				return sel, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// expandFields validates and expands every selector in a struct.
+func expandFields(prefix string, v interface{}, mount string) error {
+	var (
+		val = reflect.ValueOf(v).Elem()
+		typ = val.Type()
+		sel *selector
+		err error
+	)
+
+	for i := 0; i < val.NumField(); i++ {
+		name := fmt.Sprintf("%s.%s", prefix, typ.Field(i).Tag.Get("json"))
+
+		// A `null' in the profile clears the selector.
+		if sel = val.Field(i).Interface().(*selector); sel == nil {
+			return fmt.Errorf("selector `%s' is empty", name)
+		}
+		sel.name = name
+
+		if err = sel.expand(mount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// expand validates the strategies of a selector, substitutes the base XPath
+// and sorts them by precedence.
+func (s *selector) expand(mount string) error {
+	var (
+		i   int
+		err error
+	)
+
+	if len(s.chain) == 0 {
+		return fmt.Errorf("selector `%s' is empty", s.name)
+	}
+
+	for i = range s.chain {
+		if _, ok := strategyOrder[s.chain[i].kind]; !ok {
+			return fmt.Errorf(
+				"selector `%s' has an unknown strategy: \"%s\"",
+				s.name, s.chain[i].kind,
+			)
+		}
+
+		if strings.TrimSpace(s.chain[i].value) == "" {
+			return fmt.Errorf(
+				"selector `%s' has an empty `%s'", s.name, s.chain[i].kind,
+			)
+		}
+
+		if s.chain[i].kind != byXpath {
+			continue
+		}
+
+		s.chain[i].value = strings.Replace(s.chain[i].value, mountVar, mount, -1)
+		if err = checkXpath(s.name, s.chain[i].value); err != nil {
+			return err
+		}
+	}
+
+	sort.SliceStable(s.chain, func(i, j int) bool {
+		return strategyOrder[s.chain[i].kind] < strategyOrder[s.chain[j].kind]
+	})
+
+	return nil
+}
+
+// UnmarshalJSON reads a selector from a profile (JSON).
+func (s *selector) UnmarshalJSON(buf []byte) error {
+	var (
+		xp    string
+		pairs []map[string]string
+		err   error
+	)
+
+	if err = json.Unmarshal(buf, &xp); err == nil {
+		s.chain = []strategy{{kind: byXpath, value: xp}}
+		return nil
+	}
+
+	if err = json.Unmarshal(buf, &pairs); err != nil {
+		return fmt.Errorf("selector: expected an XPath or a list of strategies")
+	}
+
+	return s.load(pairs)
+}
+
+// UnmarshalYAML reads a selector from a profile (YAML).
+func (s *selector) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var (
+		xp    string
+		pairs []map[string]string
+		err   error
+	)
+
+	if err = unmarshal(&xp); err == nil {
+		s.chain = []strategy{{kind: byXpath, value: xp}}
+		return nil
+	}
+
+	if err = unmarshal(&pairs); err != nil {
+		return fmt.Errorf("selector: expected an XPath or a list of strategies")
+	}
+
+	return s.load(pairs)
+}
+
+// load reads a list of strategies (single-key objects).
+func (s *selector) load(pairs []map[string]string) error {
+	var (
+		pair  map[string]string
+		kind  string
+		value string
+	)
+
+	s.chain = nil
+	for _, pair = range pairs {
+		if len(pair) != 1 {
+			return fmt.Errorf(
+				"selector: expected a single strategy per entry, got %d",
+				len(pair),
+			)
+		}
+
+		for kind, value = range pair {
+			s.chain = append(s.chain, strategy{kind: kind, value: value})
+		}
+	}
+
+	return nil
+}
+
+// String describes the strategies of a selector.
+func (s *selector) String() string {
+	var (
+		st    strategy
+		descr []string
+	)
+
+	for _, st = range s.chain {
+		descr = append(descr, st.String())
+	}
+
+	return strings.Join(descr, " | ")
+}
+
+// String describes a strategy.
+func (st strategy) String() string {
+	return fmt.Sprintf("%s=%q", st.kind, st.value)
+}
+
+// xpath converts a strategy into an XPath expression.
+func (st strategy) xpath() string {
+	switch st.kind {
+	case byID:
+		return fmt.Sprintf(`//*[@id=%s]`, xpathLiteral(st.value))
+	case byName:
+		return fmt.Sprintf(`//*[@name=%s]`, xpathLiteral(st.value))
+	case byAria:
+		return fmt.Sprintf(`//*[@aria-label=%s]`, xpathLiteral(st.value))
+	case byText:
+		return fmt.Sprintf(
+			`//*[self::button or self::label or @role="button"]`+
+				`[normalize-space(.)=%s]`,
+			xpathLiteral(st.value),
+		)
+	}
+
+	return st.value
+}
+
+// xpathLiteral quotes a string for use in an XPath expression.
+func xpathLiteral(s string) string {
+	var parts []string
+
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+
+	if !strings.Contains(s, `'`) {
+		return `'` + s + `'`
+	}
+
+	for _, p := range strings.Split(s, `"`) {
+		parts = append(parts, `"`+p+`"`)
+	}
+
+	return "concat(" + strings.Join(parts, `, '"', `) + ")"
+}
+
+// find finds the first strategy that matches an element on the page, and
+// returns its index (or -1, with the first error, if nothing matches).
+func (s *selector) find(ctx context.Context) (int, error) {
+	var (
+		i     int
+		ok    bool
+		first error
+		err   error
+	)
+
+	for i = range s.chain {
+		ok, err = jsEval(
+			ctx, fmt.Sprintf(netflixEval, s.chain[i].xpath(), " !== null"),
+		)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+
+		if ok {
+			return i, nil
+		}
+	}
+
+	return -1, first
+}
+
+// match finds the first strategy that matches an element on the page,
+// and returns its XPath.
+func (s *selector) match(ctx context.Context) (string, bool, error) {
+	var (
+		i   int
+		err error
+	)
+
+	if i, err = s.find(ctx); i < 0 {
+		return "", false, err
+	}
+
+	s.logMatch(i)
+	return s.chain[i].xpath(), true, nil
+}
+
+// logMatch logs the strategy that matched; a match on anything but the first
+// strategy means that the page has drifted from the profile.
+func (s *selector) logMatch(i int) {
+	if i == 0 {
+		if Verbose {
+			dbgColor(
+				os.Stderr, "DBG: Found `%s' by %s.\n", s.name, s.chain[i],
+			)
+		}
+		return
+	}
+
+	wrnColor(
+		os.Stderr,
+		"WRN: Found `%s' by %s (fallback, tried: %s).\n",
+		s.name, s.chain[i], s.String(),
+	)
+}
+
+// element is a selector, resolved on the current page.
+type element struct {
+	sel   *selector
+	xpath string
+}
+
+// newElement creates an (unresolved) element for a selector.
+func newElement(sel *selector) *element {
+	return &element{sel: sel}
+}
+
+// resolve waits for any of the strategies to match, and
+// then waits for the element to be visible.
+func (e *element) resolve() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var (
+			ok  bool
+			err error
+		)
+
+		for {
+			e.xpath, ok, err = e.sel.match(ctx)
+			if ok {
+				return browser(ctx).WaitVisible(ctx, e.xpath)
+			}
+
+			select {
+			case <-ctx.Done():
+				if err == nil {
+					err = ctx.Err()
+				}
+				return fmt.Errorf(
+					"unable to find `%s' (%s): %s", e.sel.name, e.sel, err,
+				)
+			case <-time.After(netflixPollWait * time.Millisecond):
+			}
+		}
+	})
+}
+
+// sendKeys types into the element.
+func (e *element) sendKeys(v string) chromedp.Action {
+	return e.do(func(ctx context.Context, xp string) error {
+		return browser(ctx).SendKeys(ctx, xp, v)
+	})
+}
+
+// click clicks on the element.
+func (e *element) click() chromedp.Action {
+	return e.do(func(ctx context.Context, xp string) error {
+		return browser(ctx).Click(ctx, xp)
+	})
+}
+
+// do runs an action on the element, resolving it first (if required).
+func (e *element) do(fn func(context.Context, string) error) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+
+		if e.xpath == "" {
+			if err = e.resolve().Do(ctx); err != nil {
+				return err
+			}
+		}
+
+		return fn(ctx, e.xpath)
+	})
+}
+
+// parentXpath strips the last step from an XPath expression
+// (returns an empty string if there is no parent).
+func parentXpath(xp string) string {
+	var (
+		depth int
+		quote rune
+		last  = -1
+	)
+
+	for i, c := range xp {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+		case c == '/' && depth == 0:
+			last = i
+		}
+	}
+
+	xp = strings.TrimRight(xp[:maxInt(last, 0)], "/")
+	if xp == "" {
+		return ""
+	}
+
+	return xp
+}
+
+// maxInt returns the larger of two integers.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// checkXpath does a sanity check on an XPath expression.
+func checkXpath(name, xp string) error {
+	var (
+		stack []rune
+		quote rune
+		open  = map[rune]rune{']': '[', ')': '('}
+	)
+
+	if strings.TrimSpace(xp) == "" {
+		return fmt.Errorf("selector `%s' is empty", name)
+	}
+
+	if !strings.HasPrefix(xp, "/") && !strings.HasPrefix(xp, "(") {
+		return fmt.Errorf(
+			"selector `%s' is not an absolute XPath: \"%s\"", name, xp,
+		)
+	}
+
+	for _, c := range xp {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '(':
+			stack = append(stack, c)
+		case c == ']' || c == ')':
+			if len(stack) == 0 || stack[len(stack)-1] != open[c] {
+				return fmt.Errorf(
+					"selector `%s' has an unbalanced `%c': \"%s\"", name, c, xp,
+				)
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if quote != 0 {
+		return fmt.Errorf(
+			"selector `%s' has an unterminated string: \"%s\"", name, xp,
+		)
+	}
+
+	if len(stack) != 0 {
+		return fmt.Errorf(
+			"selector `%s' has an unbalanced `%c': \"%s\"",
+			name, stack[len(stack)-1], xp,
+		)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package rotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// selParams is a struct for running selector profile tests.
+type selParams struct {
+	name    string // Name of the profile file.
+	profile string // Contents of the profile.
+	output  string // Expected error (substring), empty if none.
+
+	// The selector to check, and its expected strategies (if no error).
+	get  func(*Selectors) *selector
+	want string
+
+	comment string // What the test does.
+}
+
+// All the test cases go here.
+var SelTests = []selParams{
+	selParams{
+		name:    "override.json",
+		profile: `{"login": {"username": [{"name": "email"}, {"id": "email"}]}}`,
+		get:     func(s *Selectors) *selector { return s.Login.Username },
+		want:    `id="email" | name="email"`,
+		comment: "Test overriding a selector, and the strategy order (JSON).",
+	},
+	selParams{
+		name:    "builtin.json",
+		profile: `{"login": {"username": "//*[@id='email']"}}`,
+		get:     func(s *Selectors) *selector { return s.Login.Submit },
+		want: `text="Sign In" | xpath="//*[@id=\"appMountPoint\"]` +
+			`/div/div[3]/div/div/div[1]/form/button"`,
+		comment: "Test retaining the built-in selectors.",
+	},
+	selParams{
+		name:    "mount.yaml",
+		profile: "mount: //main\nlogin:\n  submit: \"{mount}/form/button\"\n",
+		get:     func(s *Selectors) *selector { return s.Login.Submit },
+		want:    `xpath="//main/form/button"`,
+		comment: "Test overriding the base XPath (YAML).",
+	},
+	selParams{
+		name: "chain.yaml",
+		profile: "update:\n  submit:\n" +
+			"    - xpath: //form/button\n" +
+			"    - aria: Save password\n",
+		get:     func(s *Selectors) *selector { return s.Update.Submit },
+		want:    `aria="Save password" | xpath="//form/button"`,
+		comment: "Test a chain of strategies (YAML).",
+	},
+	selParams{
+		name:    "profiles.yaml",
+		profile: "profiles:\n  gate:\n    - aria: Who's watching?\n",
+		get:     func(s *Selectors) *selector { return s.Profiles.Gate },
+		want:    `aria="Who's watching?"`,
+		comment: "Test overriding the profile gate selector (YAML).",
+	},
+	selParams{
+		name:    "typo.json",
+		profile: `{"login": {"usrname": "//*[@id='email']"}}`,
+		output:  "unknown field",
+		comment: "Test an unknown selector (JSON).",
+	},
+	selParams{
+		name:    "typo.yml",
+		profile: "update:\n  sbumit: //button\n",
+		output:  "not found",
+		comment: "Test an unknown selector (YAML).",
+	},
+	selParams{
+		name:    "strategy.json",
+		profile: `{"update": {"submit": [{"css": "button"}]}}`,
+		output:  "selector `update.submit' has an unknown strategy: \"css\"",
+		comment: "Test an unknown strategy.",
+	},
+	selParams{
+		name:    "multi.json",
+		profile: `{"update": {"submit": [{"id": "a", "name": "b"}]}}`,
+		output:  "expected a single strategy per entry",
+		comment: "Test multiple strategies in an entry.",
+	},
+	selParams{
+		name:    "empty.json",
+		profile: `{"update": {"eval": " "}}`,
+		output:  "selector `update.eval' has an empty `xpath'",
+		comment: "Test an empty selector.",
+	},
+	selParams{
+		name:    "null.json",
+		profile: `{"login": {"username": null}}`,
+		output:  "selector `login.username' is empty",
+		comment: "Test a null selector (JSON).",
+	},
+	selParams{
+		name:    "null.yaml",
+		profile: "login:\n  username: ~\n",
+		output:  "selector `login.username' is empty",
+		comment: "Test a null selector (YAML).",
+	},
+	selParams{
+		name:    "bracket.json",
+		profile: `{"update": {"submit": "//form/button[1"}}`,
+		output:  "selector `update.submit' has an unbalanced `['",
+		comment: "Test an unbalanced bracket.",
+	},
+	selParams{
+		name:    "relative.json",
+		profile: `{"login": {"remember": "label"}}`,
+		output:  "selector `login.remember' is not an absolute XPath",
+		comment: "Test a relative XPath.",
+	},
+}
+
+// TestLoadSelectors tests loading selector profiles.
+func TestLoadSelectors(t *testing.T) {
+	var (
+		dir  string
+		path string
+		test selParams
+		sel  *Selectors
+		err  error
+	)
+
+	if sel, err = LoadSelectors(""); err != nil {
+		t.Fatalf("error: unable to load the built-in selectors: %s", err)
+	}
+
+	if strings.Contains(sel.Update.Submit.String(), mountVar) {
+		t.Fatalf("expected the base XPath to be expanded: %s", sel.Update.Submit)
+	}
+
+	dir, err = ioutil.TempDir("", "nflx-sel-test")
+	if err != nil {
+		t.Fatalf("error: unable to create a temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, test = range SelTests {
+		path = filepath.Join(dir, test.name)
+		if err = ioutil.WriteFile(path, []byte(test.profile), 0600); err != nil {
+			t.Fatalf("error: unable to write the profile: %s", err)
+		}
+
+		sel, err = LoadSelectors(path)
+		if test.output != "" {
+			if err == nil || !strings.Contains(err.Error(), test.output) {
+				t.Fatalf(
+					"\nComment: %s\n\nerror:\n\twant:\t\"%s\"\n\tgot:\t\"%v\"\n",
+					test.comment, test.output, err,
+				)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Fatalf("\nComment: %s\n\nunexpected error: %s\n", test.comment, err)
+		}
+
+		if test.get(sel).String() != test.want {
+			t.Fatalf(
+				"\nComment: %s\n\nselector:\n\twant:\t%s\n\tgot:\t%s\n",
+				test.comment, test.want, test.get(sel),
+			)
+		}
+	}
+}
+
+// TestXpathLiteral tests quoting strings in XPath expressions.
+func TestXpathLiteral(t *testing.T) {
+	var tests = map[string]string{
+		`Sign In`:     `"Sign In"`,
+		`Say "hi"`:    `'Say "hi"'`,
+		`It's "this"`: `concat("It's ", '"', "this", '"', "")`,
+	}
+
+	for in, want := range tests {
+		if got := xpathLiteral(in); got != want {
+			t.Fatalf("xpathLiteral(%s):\n\twant:\t%s\n\tgot:\t%s\n", in, want, got)
+		}
+	}
+}
+
+// TestParentXpath tests stripping the last step from XPath expressions.
+func TestParentXpath(t *testing.T) {
+	var tests = map[string]string{
+		`//*[@id="lbl-password"]/div`: `//*[@id="lbl-password"]`,
+		`//form/div[1]/div[2]`:        `//form/div[1]`,
+		`//a[@href="/x/y"]//span`:     `//a[@href="/x/y"]`,
+		`//*[@id="password"]`:         ``,
+	}
+
+	for in, want := range tests {
+		if got := parentXpath(in); got != want {
+			t.Fatalf("parentXpath(%s):\n\twant:\t%s\n\tgot:\t%s\n", in, want, got)
+		}
+	}
+}
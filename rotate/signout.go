@@ -0,0 +1,50 @@
+package rotate
+
+import "context"
+
+// SignOutDevices logs in with the old password, and signs out of all the
+// devices, without changing the password. The result is returned even on
+// errors; the sign-out is reported only once it is confirmed.
+func (r *Rotator) SignOutDevices(ctx context.Context) (out *Result, err error) {
+	var (
+		so      DeviceSignOuter
+		ok      bool
+		phase   *PhaseResult
+		bwsrCtx context.Context
+		finish  func(error)
+	)
+
+	out = &Result{Phases: []*PhaseResult{}}
+
+	if so, ok = r.site.(DeviceSignOuter); !ok {
+		return out, NewError(
+			PhaseSetup, KindFlagFail, nil,
+			"Unable to sign out of all devices on \""+r.site.Name()+"\"",
+		)
+	}
+
+	// Start the browser, and login to the site.
+	bwsrCtx, finish, err = r.login(ctx, out)
+	defer func() { finish(err) }()
+	if err != nil {
+		return out, err
+	}
+
+	// Sign out of all devices.
+	phase, err = so.SignOutDevices(bwsrCtx)
+	out.add(phase)
+	if err != nil {
+		return out, flowError(PhaseSignout, err)
+	}
+
+	// Check if the sign-out was confirmed.
+	if phase.State != StateSignedOut {
+		return out, NewError(
+			PhaseSignout, KindUpdateFail, nil,
+			"Unable to sign out of all devices: "+Pick(phase.Reason, phase.State),
+		)
+	}
+
+	out.DevicesSignedOut = true
+	return out, nil
+}
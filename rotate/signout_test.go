@@ -0,0 +1,103 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/internal/nflxmock"
+)
+
+// TestSignOutDevices tests signing out of all devices (without changing the
+// password), and checking the confirmation.
+func TestSignOutDevices(t *testing.T) {
+	var tests = []struct {
+		name     string
+		password string
+		next     string // The page after the sign-out is submitted.
+		kind     *Kind  // Expected kind of error (nil for none).
+	}{
+		{name: "success", password: "old", next: "signed-out"},
+		{name: "incorrect password", password: "bad", kind: KindIncorrectPass},
+		{
+			name: "not confirmed", password: "old", next: "login-error",
+			kind: KindUpdateFail,
+		},
+	}
+
+	for _, test := range tests {
+		var (
+			r   *Rotator
+			res *Result
+			f   = newFakeBrowser()
+			a   = &fakeAccount{password: "old"}
+			err error
+		)
+
+		r, err = New(Options{
+			Username:    "stub@example.com",
+			OldPassword: test.password,
+			BaseURL:     "http://fake.test",
+			LoginPath:   "/login",
+			SignoutPath: "/signout",
+			Browser:     f,
+		})
+		if err != nil {
+			t.Fatalf("%s: unable to create a rotator: %s", test.name, err)
+		}
+		a.script(f, r)
+
+		var (
+			d    = r.site.(*netflixDriver)
+			s    = d.sel
+			next = test.next
+		)
+
+		if u := d.routes.signoutURL(); u != "http://fake.test/signout" {
+			t.Fatalf("%s: unexpected sign-out URL: %s", test.name, u)
+		}
+
+		f.add("signout", &fakePage{
+			url:   d.routes.signoutURL(),
+			elems: map[string]string{xp(s.Signout.Submit): ""},
+			clicks: map[string]func(*fakeBrowser) (string, error){
+				xp(s.Signout.Submit): func(*fakeBrowser) (string, error) {
+					return next, nil
+				},
+			},
+		})
+
+		f.add("signed-out", &fakePage{
+			elems: map[string]string{xp(s.Signout.Eval): nflxmock.MsgSignedOut},
+		})
+
+		res, err = r.SignOutDevices(context.Background())
+		if KindOf(err) != test.kind {
+			t.Fatalf(
+				"%s:\n\twant:\t%v\n\tgot:\t%v (%v)\n",
+				test.name, test.kind, KindOf(err), err,
+			)
+		}
+
+		switch {
+		case res.DevicesSignedOut != (test.kind == nil):
+			t.Fatalf("%s: unexpected result: %+v", test.name, res)
+		case res.Updated || a.password != "old":
+			t.Fatalf("%s: the password was changed", test.name)
+		}
+	}
+
+	// The sign-out path is relative to the base URL.
+	if _, err := New(Options{SignoutPath: "signout"}); err == nil {
+		t.Fatalf("New: expected an invalid sign-out path to fail")
+	}
+
+	// The sign-out is not supported by every site.
+	Register("stub", func(Options) (SiteDriver, error) { return &stubDriver{}, nil })
+	defer delete(sites, "stub")
+
+	r, _ := New(Options{Site: "stub", Browser: newFakeBrowser()})
+	if _, err := r.SignOutDevices(context.Background()); !errors.Is(err, KindFlagFail) {
+		t.Fatalf("SignOutDevices: unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// SiteDriver drives the pages of a site: logging in, and changing the
+// password. Drivers are registered by name (see Register), and picked
+// with Options.Site.
+type SiteDriver interface {
+	// Name returns the name of the site (e.g., `netflix').
+	Name() string
+
+	// BaseURL returns the base URL of the site.
+	BaseURL() string
+
+	// Login logs in (from a new browser), and returns once the password
+	// page shows up, or the login fails (the state is StateError).
+	Login(
+		ctx context.Context, phase, username, password string,
+	) (*PhaseResult, error)
+
+	// ChangePassword updates the password (from the password page), and
+	// returns once the update is confirmed (the state is StateSuccess), or
+	// fails (the state is StateError). This also reports if the update was
+	// submitted, because the outcome is unknown on errors after that; see
+	// Submitting, which is to be called right before submitting it.
+	ChangePassword(
+		ctx context.Context, old, new string, devLogout bool,
+	) (*PhaseResult, bool, error)
+
+	// FailureReason extracts (and classifies) the failure message on the
+	// page, for a phase (PhaseLogin or PhaseUpdate).
+	FailureReason(ctx context.Context, phase string) (*Error, bool)
+}
+
+// submitKey is the context key for the hook on submitting the update.
+type submitKey struct{}
+
+// withSubmit returns a context, which calls the given function right before
+// the update is submitted (see Submitting).
+func withSubmit(ctx context.Context, fn func() error) context.Context {
+	return context.WithValue(ctx, submitKey{}, fn)
+}
+
+// Submitting is called by the drivers (from ChangePassword) right before
+// the update is submitted, once nothing else can go wrong before that; it
+// reports the step (StepSubmitting). On errors, the update must not be
+// submitted, and the error is to be returned as is.
+func Submitting(ctx context.Context) error {
+	if fn, ok := ctx.Value(submitKey{}).(func() error); ok {
+		return fn()
+	}
+
+	return nil
+}
+
+// SelectorChecker is implemented by the drivers that can check their
+// selectors against the pages (see Rotator.Doctor).
+type SelectorChecker interface {
+	// CheckSelectors checks the selectors; the password page is checked
+	// only if the credentials are given.
+	CheckSelectors(
+		ctx context.Context, username, password string,
+	) ([]SelectorCheck, error)
+}
+
+// DeviceSignOuter is implemented by the drivers that can sign out of all
+// the devices, without changing the password (see Rotator.SignOutDevices).
+type DeviceSignOuter interface {
+	// SignOutDevices signs out of all devices (once logged in), and
+	// returns once the sign-out is confirmed (the state is StateSignedOut),
+	// or fails (the state is StateError).
+	SignOutDevices(ctx context.Context) (*PhaseResult, error)
+}
+
+// SiteFunc creates a driver for a site, from the options.
+type SiteFunc func(opts Options) (SiteDriver, error)
+
+// sites are the registered drivers, by name.
+var sites = map[string]SiteFunc{
+	SiteNetflix: newNetflixDriver,
+}
+
+// Register registers a driver for a site; registering the same name twice
+// replaces the previous driver.
+func Register(name string, fn SiteFunc) {
+	sites[name] = fn
+}
+
+// Sites returns the names of the registered sites (sorted).
+func Sites() []string {
+	var names []string
+
+	for name := range sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// newSiteDriver creates the driver for a site.
+func newSiteDriver(opts Options) (SiteDriver, error) {
+	var (
+		fn SiteFunc
+		ok bool
+	)
+
+	if fn, ok = sites[Pick(opts.Site, SiteNetflix)]; !ok {
+		return nil, fmt.Errorf(
+			"unknown site: \"%s\" (known: %v)", opts.Site, Sites(),
+		)
+	}
+
+	return fn(opts)
+}
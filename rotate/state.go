@@ -0,0 +1,535 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/chromedp/chromedp"
+)
+
+// Page states, detected on each step of the browser flow.
+const (
+	StateUnknown   = "unknown"           // None of the below.
+	StateLogin     = "login-form"        // The login page.
+	StatePassword  = "password-form"     // The password page.
+	StateProfiles  = "profile-gate"      // The "Who's watching?" page.
+	StateChallenge = "verification-code" // A verification code challenge.
+	StateError     = "error-banner"      // A failure message on the page.
+	StateSuccess   = "success"           // The password was updated.
+	StateSignout   = "signout-page"      // The page to sign out of all devices.
+	StateSignedOut = "signed-out"        // All the devices were signed out.
+)
+
+// Phases of the browser flow.
+const (
+	PhaseLogin   = "login"   // Login with the old password.
+	PhaseUpdate  = "update"  // Update the password.
+	PhaseVerify  = "verify"  // Login with the new password.
+	PhaseResolve = "resolve" // Find out which password works.
+	PhasePersist = "persist" // Persist the new password (see Options).
+	PhaseSetup   = "setup"   // Everything before the browser is started.
+	PhaseDoctor  = "doctor"  // Check the selectors against the pages.
+	PhaseSignout = "signout" // Sign out of all devices.
+)
+
+// stateReasons describe the states which are not handled by a flow.
+var stateReasons = map[string]string{
+	StateUnknown:   "none of the known elements are on the page",
+	StateLogin:     "the login page was not expected",
+	StatePassword:  "the password page was not expected",
+	StateProfiles:  "a profile needs to be selected",
+	StateChallenge: "a verification code is required",
+	StateError:     "a failure message was not expected",
+	StateSuccess:   "the update confirmation was not expected",
+	StateSignout:   "the sign-out page was not expected",
+	StateSignedOut: "the sign-out confirmation was not expected",
+}
+
+// pageError is returned when the flow lands on a page it cannot handle.
+type pageError struct {
+	state  string // The detected state.
+	url    string // The current URL.
+	reason string // Why the page could not be handled.
+}
+
+// Error satisfies the error interface.
+func (e *pageError) Error() string {
+	return fmt.Sprintf("%s at \"%s\": %s", e.state, e.url, e.reason)
+}
+
+// kind maps the state to a kind of error.
+func (e *pageError) kind() *Kind {
+	switch e.state {
+	case StateLogin:
+		return KindLoginFail
+	case StatePassword, StateSignout:
+		return KindUpdateFail
+	}
+
+	return KindPageFail
+}
+
+// newPageError creates a pageError for the current page.
+func newPageError(ctx context.Context, state, reason string) *pageError {
+	var (
+		loc string
+		err error
+	)
+
+	if loc, err = browser(ctx).Location(ctx); err != nil {
+		loc = "N/A"
+	}
+
+	return &pageError{state: state, url: loc, reason: reason}
+}
+
+// stateHandler handles a page; this is called every time the page is
+// detected, with the number of times it was detected (so far).
+type stateHandler func(ctx context.Context, visits int) error
+
+// PhaseResult is the outcome of a phase (login or update).
+type PhaseResult struct {
+	Phase   string        // Name of the phase.
+	State   string        // The state the phase ended in.
+	Elapsed time.Duration // How long the phase took.
+	Reason  string        // The failure message (if any).
+}
+
+// report prints how long the phase took.
+func (r *PhaseResult) report() {
+	infColor(
+		os.Stderr,
+		"INF: The %s phase took %s (%s).\n",
+		r.Phase, r.Elapsed.Round(time.Millisecond), r.State,
+	)
+}
+
+// stateMachine drives the browser through the pages, by detecting the page
+// on each step and dispatching the handler for it.
+type stateMachine struct {
+	phase    string
+	sel      *Selectors
+	handlers map[string]stateHandler
+	visits   map[string]int
+}
+
+// newStateMachine creates a state machine (without any handlers).
+func newStateMachine(phase string, sel *Selectors) *stateMachine {
+	return &stateMachine{
+		phase:    phase,
+		sel:      sel,
+		handlers: make(map[string]stateHandler),
+		visits:   make(map[string]int),
+	}
+}
+
+// detectState detects the state of the current page. The checks are
+// ordered, such that outcomes (success, errors) take precedence.
+func detectState(ctx context.Context, s *Selectors) (string, error) {
+	var (
+		i      int
+		sel    *selector
+		first  error
+		err    error
+		checks = []struct {
+			state string
+			sels  []*selector
+		}{
+			{StateSuccess, []*selector{s.Update.Eval}},
+			{StateSignedOut, []*selector{s.Signout.Eval}},
+			{StateError, []*selector{
+				s.Login.Eval,
+				s.Login.UsernameErr,
+				s.Login.PasswordErr,
+				s.Login.FailErr,
+				s.Update.OldPasswordErr,
+				s.Update.NewPasswordErr,
+				s.Update.CnfPasswordErr,
+			}},
+			{StatePassword, []*selector{s.Update.OldPassword}},
+			{StateLogin, []*selector{s.Login.Username}},
+			{StateProfiles, []*selector{s.Profiles.Gate}},
+			{StateChallenge, []*selector{s.Challenge.Code}},
+			{StateSignout, []*selector{s.Signout.Submit}},
+		}
+	)
+
+	for _, check := range checks {
+		for _, sel = range check.sels {
+			if i, err = sel.find(ctx); i >= 0 {
+				return check.state, nil
+			}
+
+			if first == nil {
+				first = err
+			}
+		}
+	}
+
+	return StateUnknown, first
+}
+
+// waitState polls the page until a known state (other than the previous
+// one) is detected, or until the deadline; this returns the last state.
+// An unknown page is also returned once it stays for `settle' (unless it
+// is negative, i.e., only at the deadline).
+func waitState(
+	ctx context.Context,
+	s *Selectors,
+	prev string,
+	deadline time.Time,
+	settle time.Duration,
+) (string, error) {
+	var (
+		state   string
+		unknown time.Time // Since when the page is unknown.
+		err     error
+	)
+
+	for {
+		state, err = detectState(ctx, s)
+		if state != StateUnknown && state != prev {
+			return state, nil
+		}
+
+		switch {
+		case state != StateUnknown:
+			unknown = time.Time{}
+		case unknown.IsZero():
+			unknown = time.Now()
+		}
+
+		if !unknown.IsZero() && settle >= 0 && time.Since(unknown) >= settle {
+			return state, nil
+		}
+
+		if !time.Now().Before(deadline) {
+			return state, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+			return state, err
+		case <-time.After(netflixPollWait * time.Millisecond):
+		}
+	}
+}
+
+// phaseKey is the context key for the time a phase may take.
+type phaseKey struct{}
+
+// withPhaseWait returns a context, in which a phase (or a step waiting on
+// the page) may take up to the given duration.
+func withPhaseWait(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, phaseKey{}, d)
+}
+
+// phaseWait returns the time a phase may take (`netflixPhaseWait' seconds,
+// unless set by withPhaseWait).
+func phaseWait(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(phaseKey{}).(time.Duration); ok && d > 0 {
+		return d
+	}
+
+	return netflixPhaseWait * time.Second
+}
+
+// run runs the state machine until any of the given states is reached,
+// or until phaseWait has passed (not counting the pauses,
+// see pauser, nor the time the handlers spent waiting for the operator).
+// In a headful browser, the flow pauses on an unknown page (e.g., a CAPTCHA)
+// as soon as it settles, before it is handled (if at all).
+func (m *stateMachine) run(ctx context.Context, until ...string) (*PhaseResult, error) {
+	var (
+		prev     string
+		handler  stateHandler
+		ok       bool
+		held     bool // Paused on the unknown page (since the last handler).
+		pe       *pageError
+		pause    = pauser(ctx)
+		paused   time.Time
+		waited   time.Duration
+		settle   time.Duration
+		err      error
+		start    = time.Now()
+		deadline = start.Add(phaseWait(ctx))
+		res      = &PhaseResult{Phase: m.phase, State: StateUnknown}
+	)
+
+	defer func() { res.Elapsed = time.Since(start) }()
+
+	for {
+		switch {
+		case pause == nil:
+			settle = -1
+		case held:
+			settle = 0
+		default:
+			settle = netflixSettleWait * time.Millisecond
+		}
+
+		res.State, err = waitState(ctx, m.sel, prev, deadline, settle)
+		if err != nil {
+			return res, err
+		}
+
+		if Verbose {
+			dbgColor(os.Stderr, "DBG: Detected page: %s.\n", res.State)
+		}
+
+		for _, u := range until {
+			if res.State == u {
+				return res, nil
+			}
+		}
+
+		handler, ok = m.handlers[res.State]
+		switch {
+		case res.State == StateUnknown && pause != nil && !held:
+			held = true
+			err = newPageError(ctx, res.State, stateReasons[res.State])
+		case !ok:
+			err = newPageError(ctx, res.State, stateReasons[res.State])
+		default:
+			held = false
+			m.visits[res.State]++
+
+			// The time spent on a prompt (e.g., for the verification
+			// code) does not count against the phase.
+			waited = pausedFor(ctx)
+			err = handler(ctx, m.visits[res.State])
+			deadline = deadline.Add(pausedFor(ctx) - waited)
+		}
+
+		if err != nil {
+			// In a headful browser, the operator can take care of the page.
+			if pause == nil || !errors.As(err, &pe) {
+				return res, err
+			}
+
+			paused = time.Now()
+			if err = pause(ctx, pe, m.ready(ctx, pe.state, until)); err != nil {
+				return res, err
+			}
+			deadline = deadline.Add(time.Since(paused))
+
+			// Detect the page again, even if it did not change.
+			prev = ""
+			continue
+		}
+
+		prev = res.State
+	}
+}
+
+// ready returns a check for a paused flow, which reports if the page moved
+// on (from the given state) to one that the state machine can handle.
+func (m *stateMachine) ready(
+	ctx context.Context, state string, until []string,
+) func() bool {
+	return func() bool {
+		var s, _ = detectState(ctx, m.sel)
+
+		if s == state || s == StateUnknown {
+			return false
+		}
+
+		for _, u := range until {
+			if s == u {
+				return true
+			}
+		}
+
+		_, ok := m.handlers[s]
+		return ok
+	}
+}
+
+// navigateOnce returns a handler, which navigates to a URL (only once).
+func navigateOnce(state, url string) stateHandler {
+	return func(ctx context.Context, visits int) error {
+		if visits > 1 {
+			return newPageError(ctx, state, stateReasons[state])
+		}
+
+		return exec(ctx, chromedp.Tasks{navigate(url)})
+	}
+}
+
+// loginTasks are the tasks of the login phase; the ones for the pages that
+// show up only sometimes are compiled once they are needed.
+type loginTasks struct {
+	start     chromedp.Tasks // Run first.
+	login     chromedp.Tasks // Run on the login page.
+	challenge func(code string) (chromedp.Tasks, error)
+	pin       func(pin string) (chromedp.Tasks, error)
+	profile   string // The profile to pick (see Options.Profile).
+}
+
+// runLogin logs into Netflix (the start tasks are run first, the login
+// tasks on the login page, and the challenge tasks with the verification
+// code), and returns once the password page (or a failure message) shows up.
+func runLogin(
+	ctx context.Context,
+	phase string,
+	routes *netflixRoutes,
+	sel *Selectors,
+	t *loginTasks,
+) (*PhaseResult, error) {
+	var (
+		m   = newStateMachine(phase, sel)
+		err error
+	)
+
+	m.handlers[StateLogin] = func(ctx context.Context, visits int) error {
+		if visits > 1 {
+			return newPageError(
+				ctx, StateLogin, "still on the login page after submitting",
+			)
+		}
+
+		return exec(ctx, t.login)
+	}
+
+	// The verification code is submitted (only once), if there is a way
+	// to get it; the handler returns once the page moves on.
+	if code := codeSource(ctx); code != nil {
+		m.handlers[StateChallenge] = func(ctx context.Context, visits int) error {
+			var (
+				val   string
+				tasks chromedp.Tasks
+				err   error
+			)
+
+			if visits > 1 {
+				return newPageError(
+					ctx, StateChallenge, "the verification code was not accepted",
+				)
+			}
+
+			if val, err = code(ctx); err != nil {
+				return newPageError(
+					ctx, StateChallenge,
+					"unable to get the verification code: "+err.Error(),
+				)
+			}
+
+			if tasks, err = t.challenge(val); err != nil {
+				return err
+			}
+
+			if err = exec(ctx, tasks); err != nil {
+				return err
+			}
+
+			_, err = waitState(
+				ctx, sel, StateChallenge,
+				time.Now().Add(phaseWait(ctx)), -1,
+			)
+			return err
+		}
+	}
+
+	// A profile is picked on the profile gate, and then the password page
+	// is loaded directly (as it is, if the login lands somewhere else).
+	m.handlers[StateProfiles] = profileGate(routes, sel, t)
+	m.handlers[StateUnknown] = navigateOnce(
+		StateUnknown, routes.passwordURL(),
+	)
+
+	if err = exec(ctx, t.start); err != nil {
+		return &PhaseResult{Phase: m.phase, State: StateUnknown}, err
+	}
+
+	return m.run(ctx, StatePassword, StateError)
+}
+
+// runUpdate updates the password (from the password page), and returns
+// once the update is confirmed (or a failure message shows up).
+func runUpdate(
+	ctx context.Context,
+	routes *netflixRoutes,
+	sel *Selectors,
+	update chromedp.Tasks,
+) (*PhaseResult, error) {
+	var m = newStateMachine(PhaseUpdate, sel)
+
+	m.handlers[StatePassword] = func(ctx context.Context, visits int) error {
+		if visits > 1 {
+			return newPageError(
+				ctx, StatePassword, "still on the password page after submitting",
+			)
+		}
+
+		return exec(ctx, update)
+	}
+
+	m.handlers[StateProfiles] = navigateOnce(
+		StateProfiles, routes.passwordURL(),
+	)
+
+	return m.run(ctx, StateSuccess, StateError)
+}
+
+// runSignout signs out of all devices (from the password page, once logged
+// in), and returns once the sign-out is confirmed (or a failure message
+// shows up).
+func runSignout(
+	ctx context.Context,
+	routes *netflixRoutes,
+	sel *Selectors,
+	signout chromedp.Tasks,
+) (*PhaseResult, error) {
+	var m = newStateMachine(PhaseSignout, sel)
+
+	m.handlers[StateSignout] = func(ctx context.Context, visits int) error {
+		if visits > 1 {
+			return newPageError(
+				ctx, StateSignout, "still on the sign-out page after submitting",
+			)
+		}
+
+		return exec(ctx, signout)
+	}
+
+	m.handlers[StatePassword] = navigateOnce(StatePassword, routes.signoutURL())
+	m.handlers[StateProfiles] = navigateOnce(StateProfiles, routes.signoutURL())
+
+	return m.run(ctx, StateSignedOut, StateError)
+}
+
+// flowError wraps an error from the browser flow.
+func flowError(phase string, err error) error {
+	var pe *pageError
+
+	if !errors.As(err, &pe) {
+		return NewError(phase, KindExecFail, err, "Browser execution failed")
+	}
+
+	return NewError(phase, pe.kind(), pe, "Unexpected page during "+phase)
+}
+
+// ambiguous reports if the outcome of an update is unknown, i.e., if the
+// flow failed after the submit button was clicked, on anything other than
+// an outcome (a failure message, or the confirmation). The update may have
+// gone through even if the flow lands back on the login or password page
+// (e.g., once the session is signed out along with the other devices).
+func ambiguous(submitted bool, err error) bool {
+	var pe *pageError
+
+	if !submitted || err == nil {
+		return false
+	}
+
+	if errors.As(err, &pe) {
+		return pe.state != StateError && pe.state != StateSuccess
+	}
+
+	return true
+}
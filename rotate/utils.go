@@ -0,0 +1,133 @@
+package rotate
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/chromedp/chromedp"
+	"github.com/fatih/color"
+)
+
+var (
+	// Color outputs.
+	okColor  = color.New(color.FgGreen).FprintfFunc()
+	inpColor = color.New(color.FgWhite).FprintfFunc()
+	dbgColor = color.New(color.FgBlue).FprintfFunc()
+	infColor = color.New(color.FgMagenta).FprintfFunc()
+	wrnColor = color.New(color.FgYellow).FprintfFunc()
+	errColor = color.New(color.FgRed).FprintfFunc()
+
+	// Verbose enables the debug messages.
+	Verbose bool
+)
+
+// Pick returns the first non-empty value (e.g., of a flag, and then of the
+// configuration file).
+func Pick(values ...string) string {
+	var v string
+
+	for _, v = range values {
+		if v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// genExecContext creates a new context to start the browser with; the
+// browser window is shown only if it is headful.
+func genExecContext(
+	ctx context.Context, tmp, exec string, headful bool,
+) (context.Context, context.CancelFunc) {
+	var execAllocOpts = []chromedp.ExecAllocatorOption{
+		chromedp.NoFirstRun,
+		chromedp.NoDefaultBrowserCheck,
+		chromedp.DisableGPU,
+		chromedp.UserDataDir(tmp),
+	}
+
+	if !headful {
+		execAllocOpts = append(execAllocOpts, chromedp.Headless)
+	}
+
+	if exec != "" {
+		execAllocOpts = append(execAllocOpts, chromedp.ExecPath(exec))
+	}
+
+	return chromedp.NewExecAllocator(ctx, execAllocOpts...)
+}
+
+// genRemoteContext creates a new context to attach to a (running) remote
+// browser with; the browser is not closed with it, only its tabs.
+func genRemoteContext(
+	ctx context.Context, wsURL string,
+) (context.Context, context.CancelFunc) {
+	return chromedp.NewRemoteAllocator(ctx, wsURL)
+}
+
+// genBrowserContext creates a new context for the browser (from an allocator
+// context, see genExecContext and genRemoteContext), which times out with the
+// given function. The browser itself outlives the timeout (until cancelled),
+// for capturing the page.
+func genBrowserContext(
+	execCtx context.Context,
+	execCancel context.CancelFunc,
+	timeout func(context.Context) (context.Context, context.CancelFunc),
+) (context.Context, context.CancelFunc) {
+	var (
+		bwsrCtx context.Context
+		waitCtx context.Context
+
+		bwsrCancel context.CancelFunc
+		waitCancel context.CancelFunc
+	)
+
+	// This is the main context for the browser.
+	bwsrCtx, bwsrCancel = chromedp.NewContext(execCtx)
+	bwsrCtx = listenConsole(bwsrCtx)
+
+	// Add a wait context for timeouts.
+	waitCtx, waitCancel = timeout(bwsrCtx)
+
+	return withCapture(waitCtx, bwsrCtx), func() {
+		waitCancel()
+		bwsrCancel()
+		execCancel()
+	}
+}
+
+// mkTmpDir creates a temporary directory for the user data.
+func mkTmpDir(path string) (string, error) {
+	return ioutil.TempDir("", path)
+}
+
+// jsEval evaluates a JavaScript expression.
+func jsEval(ctx context.Context, expr string) (bool, error) {
+	var (
+		err  error
+		eval bool
+	)
+
+	err = browser(ctx).Evaluate(ctx, expr, &eval)
+	return eval, err
+}
+
+// extractText tries to extract the text from a given selector.
+func extractText(ctx context.Context, xPath string) string {
+	var (
+		err error
+		txt string
+	)
+
+	if txt, err = browser(ctx).Text(ctx, xPath); err != nil {
+		txt = "N/A."
+	}
+
+	return txt
+}
+
+// exec runs a given set of tasks.
+func exec(ctx context.Context, tasks chromedp.Tasks) error {
+	return tasks.Do(ctx)
+}
@@ -0,0 +1,136 @@
+package rotate
+
+import (
+	"context"
+	"errors"
+	"os"
+)
+
+// freshLogin logs into Netflix in a new browser (with a new user-data
+// directory, or without the cookies of the site on a remote browser), so
+// that nothing carries over from the previous session.
+func (r *Rotator) freshLogin(
+	ctx context.Context, phase, password string,
+) (*PhaseResult, error) {
+	var (
+		res    *PhaseResult
+		cancel context.CancelFunc
+		err    error
+	)
+
+	if ctx, cancel, err = r.newBrowser(ctx); err != nil {
+		return &PhaseResult{Phase: phase, State: StateUnknown}, err
+	}
+	defer cancel()
+
+	res, err = r.site.Login(ctx, phase, r.opts.Username, password)
+	if err == nil && res.State == StateError {
+		if f, ok := r.site.FailureReason(ctx, PhaseLogin); ok {
+			res.Reason = f.Msg
+		}
+	}
+
+	if err != nil || res.State != StatePassword {
+		r.capture(ctx, phase)
+	}
+
+	return res, err
+}
+
+// verifyNewPassword checks if the new password works, by logging in with
+// it in a new browser.
+func (r *Rotator) verifyNewPassword(ctx context.Context, out *Result) error {
+	var (
+		res *PhaseResult
+		pe  *pageError
+		err error
+	)
+
+	infColor(os.Stderr, "INF: Verifying the new password (in a new browser).\n")
+
+	res, err = r.freshLogin(ctx, PhaseVerify, r.opts.NewPassword)
+	out.add(res)
+
+	// Only the login page (or a failure message on it) tells that the new
+	// password does not work; e.g., a new-device check does not.
+	switch {
+	case errors.As(err, &pe) &&
+		(pe.state == StateLogin || pe.state == StateError):
+		return NewError(
+			PhaseVerify, KindNewPwFail, pe,
+			"Unable to login with the new password",
+		)
+	case errors.As(err, &pe):
+		return NewError(
+			PhaseVerify, KindPageFail, pe,
+			"Unable to verify the new password",
+		)
+	case err != nil:
+		return NewError(
+			PhaseVerify, KindExecFail, err,
+			"Unable to verify the new password",
+		)
+	case res.State == StateError:
+		return NewError(
+			PhaseVerify, KindNewPwFail, errors.New(Pick(res.Reason, "N/A.")),
+			"The update looked fine, but the new password does not work",
+		)
+	}
+
+	infColor(os.Stderr, "INF: Logged in with the new password.\n")
+	return nil
+}
+
+// resolveState finds out which password works, when the outcome of the
+// update is unknown (e.g., the browser crashed after submitting). The new
+// password is tried first. The error is of the kind KindStateNew or
+// KindStateOld (for the password that works), or KindStateFail.
+func (r *Rotator) resolveState(ctx context.Context, out *Result) error {
+	var (
+		res        *PhaseResult
+		reason     string
+		err        error
+		candidates = []struct {
+			name     string
+			password string
+			kind     *Kind
+		}{
+			{"new", r.opts.NewPassword, KindStateNew},
+			{"old", r.opts.OldPassword, KindStateOld},
+		}
+	)
+
+	wrnColor(
+		os.Stderr,
+		"WRN: The outcome of the update is unknown; "+
+			"trying to login with the new password, and then the old one.\n",
+	)
+
+	for _, c := range candidates {
+		res, err = r.freshLogin(ctx, PhaseResolve, c.password)
+		out.add(res)
+
+		if err == nil && res.State == StatePassword {
+			return NewError(
+				PhaseResolve, c.kind, nil,
+				"State resolved: the "+c.name+" password works",
+			)
+		}
+
+		reason = Pick(res.Reason, "N/A.")
+		if err != nil {
+			reason = err.Error()
+		}
+
+		wrnColor(
+			os.Stderr,
+			"WRN: Unable to login with the %s password (%s).\n",
+			c.name, reason,
+		)
+	}
+
+	return NewError(
+		PhaseResolve, KindStateFail, nil,
+		"State unresolved: neither password works",
+	)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/clickyotomy/netflix-passwd-rotate/rotate"
+)
+
+// runSignout logs in, and signs out of all the devices (without changing
+// the password); this is not recorded in the journal.
+func runSignout(
+	ctx context.Context, opts rotate.Options, username, password, title string,
+) error {
+	var (
+		rot *rotate.Rotator
+		res *rotate.Result
+		err error
+	)
+
+	opts.Username = username
+	opts.OldPassword = password
+
+	if rot, err = rotate.New(opts); err != nil {
+		return err
+	}
+
+	res, err = rot.SignOutDevices(ctx)
+	for _, phase := range res.Phases {
+		summary.add(phase)
+	}
+	summary.DevicesSignedOut = res.DevicesSignedOut
+
+	if err != nil {
+		return err
+	}
+
+	okColor(
+		os.Stdout, "INF: All the devices were signed out of %s!\n", title,
+	)
+
+	return nil
+}